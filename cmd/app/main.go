@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/AkifhanIlgaz/hotel-booking-app/config"
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/handlers"
@@ -13,7 +15,9 @@ import (
 	"github.com/AkifhanIlgaz/hotel-booking-app/migrations"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/db"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/mail"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/oidc"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/token"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	_ "github.com/microsoft/go-mssqldb"
@@ -49,21 +53,48 @@ func main() {
 
 	mailManager := mail.NewManager(cfg.SMTP)
 
+	mfaCipher, err := utils.NewCipher(cfg.MFA.EncryptionKey)
+	if err != nil {
+		log.Fatalf("failed to create mfa cipher: %v", err)
+	}
+
+	identityProviders, err := oidc.NewRegistry(cfg.OIDC, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		log.Fatalf("failed to create identity providers: %v", err)
+	}
+
 	server := gin.Default()
 
+	// Login limits are counted per client IP, so X-Forwarded-For must only be honored from our own proxies
+	err = server.SetTrustedProxies(cfg.App.TrustedProxies)
+	if err != nil {
+		log.Fatalf("failed to set trusted proxies: %v", err)
+	}
+
 	router := server.Group("/api")
 
 	userService := services.NewUserService(db)
 	otpService := services.NewOTPService(db)
+	loginGuardService := services.NewLoginGuardService(db)
+	mfaService := services.NewMFAService(db, mfaCipher)
 	hotelService := services.NewHotelService(db)
-
-	authHandler := handlers.NewAuthHandler(userService, otpService, tokenManager, mailManager)
-	hotelHandler := handlers.NewHotelHandler(hotelService)
+	reservationService := services.NewReservationService(db)
+	roomTypeService := services.NewRoomTypeService(db)
+	managerService := services.NewManagerService(db, hotelService, reservationService)
+	identityService := services.NewIdentityService(db)
+
+	authHandler := handlers.NewAuthHandler(userService, otpService, loginGuardService, mfaService, tokenManager, mailManager, cfg.App.MagicLinkURL)
+	hotelHandler := handlers.NewHotelHandler(hotelService, roomTypeService)
+	reservationHandler := handlers.NewReservationHandler(reservationService)
+	roomTypeHandler := handlers.NewRoomTypeHandler(roomTypeService)
+	managerHandler := handlers.NewManagerHandler(managerService)
+	oidcHandler := handlers.NewOIDCHandler(identityProviders, identityService, tokenManager, authHandler)
 	authMiddleware := middlewares.NewAuthMiddleware(tokenManager)
 
-	routeManager := routes.NewManager(router, authHandler, hotelHandler, authMiddleware)
+	routeManager := routes.NewManager(router, authHandler, hotelHandler, reservationHandler, roomTypeHandler, managerHandler, oidcHandler, authMiddleware)
 
 	routeManager.SetupRoutes()
+	routeManager.SetupWellKnownRoutes(server)
 
 	port := ":8080"
 	err = server.Run(port)
@@ -23,11 +23,24 @@ type PostgresConfig struct {
 	ConnMaxIdleTimeMin int    `mapstructure:"conn_max_idle_time_minutes" validate:"required,min=0"`
 }
 
+// TokenConfig either lists a keyring in Keys or a single key pair in PrivateKeyPath and PublicKeyPath.
+// Password reset tokens are signed with ResetKey when it is set and with the keyring otherwise.
 type TokenConfig struct {
-	PrivateKeyPath        string `mapstructure:"private_key_path" validate:"required"`
-	PublicKeyPath         string `mapstructure:"public_key_path" validate:"required"`
-	AccessTokenExpiresIn  int    `mapstructure:"access_token_expires_in" validate:"required"`  // minutes
-	RefreshTokenExpiresIn int    `mapstructure:"refresh_token_expires_in" validate:"required"` // days
+	PrivateKeyPath        string             `mapstructure:"private_key_path" validate:"required_without=Keys"`
+	PublicKeyPath         string             `mapstructure:"public_key_path" validate:"required_without=Keys"`
+	Keys                  []SigningKeyConfig `mapstructure:"keys" validate:"omitempty,dive"`
+	ActiveKeyId           string             `mapstructure:"active_key_id" validate:"required_with=Keys"`
+	ResetKey              *SigningKeyConfig  `mapstructure:"reset_key"`
+	AccessTokenExpiresIn  int                `mapstructure:"access_token_expires_in" validate:"required"`  // minutes
+	RefreshTokenExpiresIn int                `mapstructure:"refresh_token_expires_in" validate:"required"` // days
+}
+
+// SigningKeyConfig is a key of the keyring, retired keys only need the public key to verify
+// the tokens they signed until those expire. A reset key needs both.
+type SigningKeyConfig struct {
+	Id             string `mapstructure:"id" validate:"required"`
+	PrivateKeyPath string `mapstructure:"private_key_path"`
+	PublicKeyPath  string `mapstructure:"public_key_path" validate:"required"`
 }
 
 type SMTPConfig struct {
@@ -37,10 +50,37 @@ type SMTPConfig struct {
 	Password string `mapstructure:"password" validate:"required"`
 }
 
+// AppConfig holds the client URLs put in emails and the proxies in front of the server.
+// Forwarded client IPs are only read from TrustedProxies, when it is empty the connection's IP is used.
+type AppConfig struct {
+	MagicLinkURL   string   `mapstructure:"magic_link_url" validate:"required,url"` // the token is appended as ?token=
+	TrustedProxies []string `mapstructure:"trusted_proxies" validate:"omitempty,dive,ip|cidr"`
+}
+
+// MFAConfig holds the base64 encoded 32 byte key the TOTP secrets are encrypted with,
+// generate one with `openssl rand -base64 32`
+type MFAConfig struct {
+	EncryptionKey string `mapstructure:"encryption_key" validate:"required,base64"`
+}
+
+// OIDCProviderConfig is an OpenID Connect issuer users can log in with, Name is used in the /auth/oidc/:provider routes.
+// Scopes default to openid, email and profile.
+type OIDCProviderConfig struct {
+	Name         string   `mapstructure:"name" validate:"required,alphanum"`
+	IssuerURL    string   `mapstructure:"issuer_url" validate:"required,url"`
+	ClientId     string   `mapstructure:"client_id" validate:"required"`
+	ClientSecret string   `mapstructure:"client_secret" validate:"required"`
+	RedirectURL  string   `mapstructure:"redirect_url" validate:"required,url"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
 type Config struct {
-	Postgres PostgresConfig `mapstructure:"postgres"`
-	Token    TokenConfig    `mapstructure:"token"`
-	SMTP     SMTPConfig     `mapstructure:"smtp"`
+	Postgres PostgresConfig       `mapstructure:"postgres"`
+	Token    TokenConfig          `mapstructure:"token"`
+	SMTP     SMTPConfig           `mapstructure:"smtp"`
+	App      AppConfig            `mapstructure:"app"`
+	MFA      MFAConfig            `mapstructure:"mfa"`
+	OIDC     []OIDCProviderConfig `mapstructure:"oidc" validate:"omitempty,dive"`
 }
 
 func Load(mod string) (Config, error) {
@@ -5,18 +5,23 @@ import (
 
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/services"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/messages"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/response"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 type HotelHandler struct {
-	hotelService *services.HotelService
+	hotelService    *services.HotelService
+	roomTypeService *services.RoomTypeService
 }
 
-func NewHotelHandler(hotelService *services.HotelService) *HotelHandler {
+func NewHotelHandler(hotelService *services.HotelService, roomTypeService *services.RoomTypeService) *HotelHandler {
 	return &HotelHandler{
-		hotelService: hotelService,
+		hotelService:    hotelService,
+		roomTypeService: roomTypeService,
 	}
 }
 func (h *HotelHandler) Hotels(ctx *gin.Context) {
@@ -29,21 +34,65 @@ func (h *HotelHandler) Hotels(ctx *gin.Context) {
 	params.Validate()
 	params.NormalizeFeatures()
 
-	hotels, err := h.hotelService.GetHotels(params)
+	if err := params.ValidateStay(); err != nil {
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidStayDates, err)
+		return
+	}
+
+	if params.UsesCursor() {
+		hotels, nextCursor, err := h.hotelService.GetHotelsByCursor(params)
+		if err != nil {
+			if errors.Is(err, errors.ErrInvalidCursor) {
+				response.WithError(ctx, http.StatusBadRequest, messages.InvalidCursor, err)
+				return
+			}
+
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+			return
+		}
+
+		response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+			"hotels":     hotels,
+			"pageSize":   params.PageSize,
+			"nextCursor": nextCursor,
+		})
+		return
+	}
+
+	hotels, total, err := h.hotelService.GetHotels(params)
 	if err != nil {
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
 	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
-		"hotels": hotels,
+		"hotels":     hotels,
+		"total":      total,
+		"page":       params.Page,
+		"pageSize":   params.PageSize,
+		"totalPages": (total + params.PageSize - 1) / params.PageSize,
 	})
 }
 
 func (h *HotelHandler) Hotel(ctx *gin.Context) {
-	hotelId := ctx.Param("id")
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	hotel, err := h.hotelService.GetHotelById(hotelId.String())
+	if err != nil {
+		if errors.Is(err, errors.ErrHotelNotFound) {
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
 
-	hotel, err := h.hotelService.GetHotelById(hotelId)
+	hotel.RoomTypes, err = h.roomTypeService.GetRoomTypes(hotelId)
 	if err != nil {
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
@@ -53,3 +102,185 @@ func (h *HotelHandler) Hotel(ctx *gin.Context) {
 		"hotel": hotel,
 	})
 }
+
+func (h *HotelHandler) Facets(ctx *gin.Context) {
+	var params models.HotelFilterParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	params.Validate()
+	params.NormalizeFeatures()
+
+	if err := params.ValidateStay(); err != nil {
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidStayDates, err)
+		return
+	}
+
+	facets, err := h.hotelService.GetHotelFacets(params)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"facets": facets,
+	})
+}
+
+func (h *HotelHandler) Create(ctx *gin.Context) {
+	var req models.HotelRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	hotel, err := h.hotelService.CreateHotel(req)
+	if err != nil {
+		if errors.Is(err, errors.ErrHotelNameTaken) {
+			response.WithError(ctx, http.StatusConflict, messages.HotelNameTaken, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusCreated, messages.HotelCreated, gin.H{
+		"hotel": hotel,
+	})
+}
+
+func (h *HotelHandler) Update(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	var req models.HotelRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	hotel, err := h.hotelService.UpdateHotel(hotelId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrHotelNameTaken):
+			response.WithError(ctx, http.StatusConflict, messages.HotelNameTaken, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.HotelUpdated, gin.H{
+		"hotel": hotel,
+	})
+}
+
+func (h *HotelHandler) Patch(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	var req models.HotelPatchRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	hotel, err := h.hotelService.PatchHotel(hotelId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrHotelNameTaken):
+			response.WithError(ctx, http.StatusConflict, messages.HotelNameTaken, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.HotelUpdated, gin.H{
+		"hotel": hotel,
+	})
+}
+
+func (h *HotelHandler) Delete(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	err = h.hotelService.DeleteHotel(hotelId)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrHotelHasReservations):
+			response.WithError(ctx, http.StatusConflict, messages.HotelHasReservations, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.HotelDeleted, nil)
+}
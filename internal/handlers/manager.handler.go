@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/services"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/messages"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type ManagerHandler struct {
+	managerService *services.ManagerService
+}
+
+func NewManagerHandler(managerService *services.ManagerService) *ManagerHandler {
+	return &ManagerHandler{
+		managerService: managerService,
+	}
+}
+
+func (h *ManagerHandler) Hotels(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	hotels, err := h.managerService.GetHotels(uid)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"hotels": hotels,
+	})
+}
+
+func (h *ManagerHandler) Update(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	var req models.ManagerHotelRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	hotel, err := h.managerService.UpdateHotel(uid, hotelId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrNotHotelStaff):
+			response.WithError(ctx, http.StatusForbidden, messages.NotHotelStaff, err)
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrHotelNameTaken):
+			response.WithError(ctx, http.StatusConflict, messages.HotelNameTaken, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.HotelUpdated, gin.H{
+		"hotel": hotel,
+	})
+}
+
+func (h *ManagerHandler) Patch(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	var req models.ManagerHotelPatchRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	hotel, err := h.managerService.PatchHotel(uid, hotelId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrNotHotelStaff):
+			response.WithError(ctx, http.StatusForbidden, messages.NotHotelStaff, err)
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrHotelNameTaken):
+			response.WithError(ctx, http.StatusConflict, messages.HotelNameTaken, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.HotelUpdated, gin.H{
+		"hotel": hotel,
+	})
+}
+
+func (h *ManagerHandler) Reservations(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	reservations, err := h.managerService.GetReservations(uid, hotelId)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotHotelStaff) {
+			response.WithError(ctx, http.StatusForbidden, messages.NotHotelStaff, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"reservations": reservations,
+	})
+}
+
+func (h *ManagerHandler) AssignStaff(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	var req models.HotelStaffRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	err = h.managerService.AssignStaff(hotelId, uuid.MustParse(req.UserId))
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrUserNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.UserNotFound, err)
+		case errors.Is(err, errors.ErrStaffRoleConflict):
+			response.WithError(ctx, http.StatusConflict, messages.StaffRoleConflict, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.StaffAssigned, nil)
+}
+
+func (h *ManagerHandler) RemoveStaff(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	userId, err := uuid.Parse(ctx.Param("userId"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.StaffNotFound, errors.ErrNotHotelStaff)
+		return
+	}
+
+	err = h.managerService.RemoveStaff(hotelId, userId)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotHotelStaff) {
+			response.WithError(ctx, http.StatusNotFound, messages.StaffNotFound, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.StaffRemoved, nil)
+}
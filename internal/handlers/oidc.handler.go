@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/services"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/messages"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/oidc"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/response"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/token"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// OIDCHandler logs users in through external identity providers,
+// a successful login is finished by AuthHandler like a password login
+type OIDCHandler struct {
+	providers       *oidc.Registry
+	identityService *services.IdentityService
+	tokenManager    *token.Manager
+	authHandler     *AuthHandler
+}
+
+func NewOIDCHandler(providers *oidc.Registry, identityService *services.IdentityService, tokenManager *token.Manager, authHandler *AuthHandler) *OIDCHandler {
+	return &OIDCHandler{
+		providers:       providers,
+		identityService: identityService,
+		tokenManager:    tokenManager,
+		authHandler:     authHandler,
+	}
+}
+
+// AuthURL returns the provider's login page URL. The client keeps state and compares it
+// with the one the provider redirects back with before calling Callback.
+func (h *OIDCHandler) AuthURL(ctx *gin.Context) {
+	provider, ok := h.providers.Get(ctx.Param("provider"))
+	if !ok {
+		response.WithError(ctx, http.StatusNotFound, messages.ProviderNotFound, errors.ErrProviderNotFound)
+		return
+	}
+
+	nonce, err := utils.RandString(32)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	state, err := h.tokenManager.GenerateOIDCState(provider.Name(), nonce)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx.Request.Context(), state, nonce)
+	if err != nil {
+		response.WithError(ctx, http.StatusBadGateway, messages.ExternalLoginFailed, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"authorization_url": authURL,
+		"state":             state,
+	})
+}
+
+func (h *OIDCHandler) Callback(ctx *gin.Context) {
+	var req models.OIDCCallbackRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	provider, ok := h.providers.Get(ctx.Param("provider"))
+	if !ok {
+		response.WithError(ctx, http.StatusNotFound, messages.ProviderNotFound, errors.ErrProviderNotFound)
+		return
+	}
+
+	stateProvider, nonce, err := h.tokenManager.ParseOIDCState(req.State)
+	if err != nil {
+		if errors.Is(err, errors.ErrTokenExpired) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenExpired, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	if stateProvider != provider.Name() {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, errors.ErrInvalidToken)
+		return
+	}
+
+	identity, err := provider.Exchange(ctx.Request.Context(), req.Code, nonce)
+	if err != nil {
+		if errors.Is(err, errors.ErrOIDCExchangeFailed) || errors.Is(err, errors.ErrInvalidIdToken) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.ExternalLoginFailed, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusBadGateway, messages.ExternalLoginFailed, err)
+		return
+	}
+
+	user, err := h.identityService.Login(provider.Name(), identity)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnverifiedIdentity) {
+			response.WithError(ctx, http.StatusForbidden, messages.UnverifiedIdentity, err)
+			return
+		}
+
+		if errors.Is(err, errors.ErrAccountLinkConflict) {
+			response.WithError(ctx, http.StatusConflict, messages.AccountLinkConflict, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	h.authHandler.completeLogin(ctx, user)
+}
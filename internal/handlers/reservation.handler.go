@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/services"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/messages"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type ReservationHandler struct {
+	reservationService *services.ReservationService
+}
+
+func NewReservationHandler(reservationService *services.ReservationService) *ReservationHandler {
+	return &ReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+func (h *ReservationHandler) Create(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	var req models.ReservationRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	reservation, err := h.reservationService.CreateReservation(uid, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrInvalidStayDates):
+			response.WithError(ctx, http.StatusBadRequest, messages.InvalidStayDates, err)
+		case errors.Is(err, errors.ErrRoomTypeNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.RoomTypeNotFound, err)
+		case errors.Is(err, errors.ErrGuestLimitExceeded):
+			response.WithError(ctx, http.StatusBadRequest, messages.GuestLimitExceeded, err)
+		case errors.Is(err, errors.ErrNoAvailability):
+			response.WithError(ctx, http.StatusConflict, messages.NoAvailability, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusCreated, messages.ReservationCreated, gin.H{
+		"reservation": reservation,
+	})
+}
+
+func (h *ReservationHandler) Reservations(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	reservations, err := h.reservationService.GetReservations(uid)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"reservations": reservations,
+	})
+}
+
+func (h *ReservationHandler) Reservation(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	reservationId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.ReservationNotFound, errors.ErrReservationNotFound)
+		return
+	}
+
+	reservation, err := h.reservationService.GetReservationById(uid, reservationId)
+	if err != nil {
+		if errors.Is(err, errors.ErrReservationNotFound) {
+			response.WithError(ctx, http.StatusNotFound, messages.ReservationNotFound, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"reservation": reservation,
+	})
+}
+
+func (h *ReservationHandler) Cancel(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	reservationId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.ReservationNotFound, errors.ErrReservationNotFound)
+		return
+	}
+
+	reservation, err := h.reservationService.CancelReservation(uid, reservationId)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrReservationNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.ReservationNotFound, err)
+		case errors.Is(err, errors.ErrAlreadyCancelled):
+			response.WithError(ctx, http.StatusConflict, messages.ReservationNotCancellable, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.ReservationCancelled, gin.H{
+		"reservation": reservation,
+	})
+}
+
+func (h *ReservationHandler) AllReservations(ctx *gin.Context) {
+	var hotelId *uuid.UUID
+	if param := ctx.Query("hotelId"); param != "" {
+		id, err := uuid.Parse(param)
+		if err != nil {
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+			return
+		}
+		hotelId = &id
+	}
+
+	reservations, err := h.reservationService.GetAllReservations(hotelId)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"reservations": reservations,
+	})
+}
+
+func (h *ReservationHandler) CancelAny(ctx *gin.Context) {
+	reservationId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.ReservationNotFound, errors.ErrReservationNotFound)
+		return
+	}
+
+	reservation, err := h.reservationService.CancelAnyReservation(reservationId)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrReservationNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.ReservationNotFound, err)
+		case errors.Is(err, errors.ErrAlreadyCancelled):
+			response.WithError(ctx, http.StatusConflict, messages.ReservationNotCancellable, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.ReservationCancelled, gin.H{
+		"reservation": reservation,
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/services"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/messages"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type RoomTypeHandler struct {
+	roomTypeService *services.RoomTypeService
+}
+
+func NewRoomTypeHandler(roomTypeService *services.RoomTypeService) *RoomTypeHandler {
+	return &RoomTypeHandler{
+		roomTypeService: roomTypeService,
+	}
+}
+
+func (h *RoomTypeHandler) Create(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	var req models.RoomTypeRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	roomType, err := h.roomTypeService.CreateRoomType(hotelId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrHotelNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, err)
+		case errors.Is(err, errors.ErrRoomTypeNameTaken):
+			response.WithError(ctx, http.StatusConflict, messages.RoomTypeNameTaken, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusCreated, messages.RoomTypeCreated, gin.H{
+		"room_type": roomType,
+	})
+}
+
+func (h *RoomTypeHandler) Update(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	roomTypeId, err := uuid.Parse(ctx.Param("roomTypeId"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.RoomTypeNotFound, errors.ErrRoomTypeNotFound)
+		return
+	}
+
+	var req models.RoomTypeRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	roomType, err := h.roomTypeService.UpdateRoomType(hotelId, roomTypeId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrRoomTypeNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.RoomTypeNotFound, err)
+		case errors.Is(err, errors.ErrRoomTypeNameTaken):
+			response.WithError(ctx, http.StatusConflict, messages.RoomTypeNameTaken, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.RoomTypeUpdated, gin.H{
+		"room_type": roomType,
+	})
+}
+
+func (h *RoomTypeHandler) Delete(ctx *gin.Context) {
+	hotelId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.HotelNotFound, errors.ErrHotelNotFound)
+		return
+	}
+
+	roomTypeId, err := uuid.Parse(ctx.Param("roomTypeId"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.RoomTypeNotFound, errors.ErrRoomTypeNotFound)
+		return
+	}
+
+	err = h.roomTypeService.DeleteRoomType(hotelId, roomTypeId)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrRoomTypeNotFound):
+			response.WithError(ctx, http.StatusNotFound, messages.RoomTypeNotFound, err)
+		case errors.Is(err, errors.ErrRoomTypeInUse):
+			response.WithError(ctx, http.StatusConflict, messages.RoomTypeInUse, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.RoomTypeDeleted, nil)
+}
@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/services"
@@ -10,23 +13,31 @@ import (
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/messages"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/response"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/token"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
-	userService  *services.UserService
-	tokenManager *token.Manager
-	otpService   *services.OTPService
-	mailManager  *mail.Manager
+	userService       *services.UserService
+	tokenManager      *token.Manager
+	otpService        *services.OTPService
+	loginGuardService *services.LoginGuardService
+	mfaService        *services.MFAService
+	mailManager       *mail.Manager
+	magicLinkURL      string
 }
 
-func NewAuthHandler(userService *services.UserService, otpService *services.OTPService, tokenManager *token.Manager, mailManager *mail.Manager) *AuthHandler {
+func NewAuthHandler(userService *services.UserService, otpService *services.OTPService, loginGuardService *services.LoginGuardService, mfaService *services.MFAService, tokenManager *token.Manager, mailManager *mail.Manager, magicLinkURL string) *AuthHandler {
 	return &AuthHandler{
-		userService:  userService,
-		tokenManager: tokenManager,
-		otpService:   otpService,
-		mailManager:  mailManager,
+		userService:       userService,
+		tokenManager:      tokenManager,
+		otpService:        otpService,
+		loginGuardService: loginGuardService,
+		mfaService:        mfaService,
+		mailManager:       mailManager,
+		magicLinkURL:      magicLinkURL,
 	}
 }
 
@@ -64,14 +75,24 @@ func (h *AuthHandler) Register(ctx *gin.Context) {
 		return
 	}
 
-	// Todo: role enum
-	accessToken, err := h.tokenManager.GenerateAccessToken(id.String(), "user")
+	user := &models.User{
+		Id:    id,
+		Email: req.Email,
+		Role:  models.RoleUser,
+	}
+
+	// The account exists at this point, a failed email can be sent again from /auth/resend-verification
+	if err := h.sendVerificationEmail(user.Email); err != nil {
+		ctx.Error(err)
+	}
+
+	accessToken, err := h.tokenManager.GenerateAccessToken(user)
 	if err != nil {
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
-	refreshToken, err := h.tokenManager.GenerateRefreshToken(id)
+	refreshToken, err := h.tokenManager.GenerateRefreshToken(id, deviceInfo(ctx))
 	if err != nil {
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
@@ -107,35 +128,37 @@ func (h *AuthHandler) Login(ctx *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.AuthenticateUser(req)
+	lockedUntil, err := h.loginGuardService.Check(req.Email, ctx.ClientIP())
 	if err != nil {
-		if errors.Is(err, errors.ErrUserNotFound) {
-			response.WithError(ctx, http.StatusNotFound, messages.UserNotFound, err)
-			return
-		}
-		if errors.Is(err, errors.ErrWrongPassword) {
-			response.WithError(ctx, http.StatusUnauthorized, messages.WrongPassword, err)
+		if errors.Is(err, errors.ErrLoginLocked) {
+			ctx.Header("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+			response.WithError(ctx, http.StatusTooManyRequests, messages.LoginLocked, err)
 			return
 		}
-	}
 
-	accessToken, err := h.tokenManager.GenerateAccessToken(user.Id.String(), "user")
-	if err != nil {
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
-	refreshToken, err := h.tokenManager.GenerateRefreshToken(user.Id)
+	user, err := h.userService.AuthenticateUser(req)
 	if err != nil {
+		// Unknown emails and wrong passwords get the same response so emails can't be enumerated,
+		// the error field included
+		if errors.Is(err, errors.ErrUserNotFound) || errors.Is(err, errors.ErrWrongPassword) {
+			if err := h.loginGuardService.RecordFailure(req.Email, ctx.ClientIP()); err != nil {
+				response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+				return
+			}
+
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidCredentials, errors.ErrInvalidCredentials)
+			return
+		}
+
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
-	response.WithSuccess(ctx, http.StatusOK, messages.SuccessfullyLoggedIn, gin.H{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
-	})
-
+	h.completeLogin(ctx, user)
 }
 
 func (h *AuthHandler) Refresh(ctx *gin.Context) {
@@ -161,21 +184,38 @@ func (h *AuthHandler) Refresh(ctx *gin.Context) {
 		return
 	}
 
-	uid, err := h.tokenManager.ValidateRefreshToken(req.RefreshToken)
+	session, err := h.tokenManager.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
-		if errors.Is(err, errors.ErrTokenExpired) {
+		switch {
+		case errors.Is(err, errors.ErrTokenExpired):
 			response.WithError(ctx, http.StatusUnauthorized, messages.TokenExpired, err)
-			return
+		case errors.Is(err, errors.ErrRefreshTokenReused):
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenReused, err)
+		default:
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
 		}
+		return
+	}
+
+	user, err := h.userService.GetUserById(session.UserId)
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
 	}
-	accessToken, err := h.tokenManager.GenerateAccessToken(uid.String(), "user")
+
+	accessToken, err := h.tokenManager.GenerateAccessToken(user)
 	if err != nil {
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
-	refreshToken, err := h.tokenManager.GenerateRefreshToken(uid)
+	refreshToken, err := h.tokenManager.RotateRefreshToken(session, deviceInfo(ctx))
 	if err != nil {
+		if errors.Is(err, errors.ErrRefreshTokenReused) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenReused, err)
+			return
+		}
+
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
@@ -210,25 +250,36 @@ func (h *AuthHandler) Logout(ctx *gin.Context) {
 		return
 	}
 
-	uid, err := h.tokenManager.ValidateRefreshToken(req.RefreshToken)
+	session, err := h.tokenManager.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
-		if errors.Is(err, errors.ErrTokenExpired) {
+		switch {
+		case errors.Is(err, errors.ErrTokenExpired):
 			response.WithError(ctx, http.StatusUnauthorized, messages.TokenExpired, err)
-			return
+		case errors.Is(err, errors.ErrNotFoundRefreshToken):
+			response.WithError(ctx, http.StatusNotFound, messages.TokenNotFound, err)
+		case errors.Is(err, errors.ErrRefreshTokenReused):
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenReused, err)
+		default:
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
 		}
+		return
 	}
 
-	err = h.tokenManager.DeleteRefreshToken(uid)
+	err = h.tokenManager.DeleteRefreshToken(session.UserId, session.Id)
 	if err != nil {
 		if errors.Is(err, errors.ErrNotFoundRefreshToken) {
 			response.WithError(ctx, http.StatusNotFound, messages.TokenNotFound, err)
 			return
 		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
 	}
 
 	response.WithSuccess(ctx, http.StatusOK, messages.SuccessfullyLoggedOut, nil)
 }
 
+// ForgotPassword emails a password reset code. It responds the same whether the account exists or not.
 func (h *AuthHandler) ForgotPassword(ctx *gin.Context) {
 	var req models.OTPRequest
 	if err := ctx.BindJSON(&req); err != nil {
@@ -255,7 +306,7 @@ func (h *AuthHandler) ForgotPassword(ctx *gin.Context) {
 	user, err := h.userService.GetUserByEmail(req.Email)
 	if err != nil {
 		if errors.Is(err, errors.ErrUserNotFound) {
-			response.WithError(ctx, http.StatusNotFound, messages.UserNotFound, err)
+			response.WithSuccess(ctx, http.StatusOK, messages.SentOTPCode, nil)
 			return
 		}
 
@@ -264,8 +315,14 @@ func (h *AuthHandler) ForgotPassword(ctx *gin.Context) {
 	}
 
 	// Todo: Create OTP code for this email
-	otpCode, err := h.otpService.GenerateOTP(user.Email)
+	otpCode, err := h.otpService.GenerateOTP(user.Email, models.OTPPurposePasswordReset)
 	if err != nil {
+		// Only an existing account can be in cooldown, the code already sent is still valid
+		if errors.Is(err, errors.ErrOTPCooldown) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentOTPCode, nil)
+			return
+		}
+
 		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
@@ -303,36 +360,30 @@ func (h *AuthHandler) VerifyOTP(ctx *gin.Context) {
 		return
 	}
 
-	valid, err := h.otpService.VerifyOTP(req.Email, req.OTP)
+	valid, err := h.otpService.VerifyOTP(req.Email, req.OTP, models.OTPPurposePasswordReset)
 	if err != nil {
 		switch {
-		case errors.Is(err, errors.ErrUserNotFound):
-			response.WithError(ctx, http.StatusNotFound, messages.UserNotFound, err)
-			return
-		case err.Error() == "invalid otp":
-			response.WithError(ctx, http.StatusUnauthorized, "Invalid OTP code", err)
-			return
-		case err.Error() == "otp expired":
-			response.WithError(ctx, http.StatusUnauthorized, "OTP code has expired", err)
-			return
-		case err.Error() == "otp already used":
-			response.WithError(ctx, http.StatusUnauthorized, "OTP code has already been used", err)
-			return
+		case errors.Is(err, errors.ErrInvalidOTP):
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidOTP, err)
+		case errors.Is(err, errors.ErrOTPExpired):
+			response.WithError(ctx, http.StatusUnauthorized, messages.OTPExpired, err)
+		case errors.Is(err, errors.ErrOTPAttemptsExceeded):
+			response.WithError(ctx, http.StatusTooManyRequests, messages.OTPAttemptsExceeded, err)
 		default:
 			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
-			return
 		}
+		return
 	}
 
 	if !valid {
-		response.WithError(ctx, http.StatusUnauthorized, "Invalid OTP code", nil)
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidOTP, errors.ErrInvalidOTP)
 		return
 	}
 
 	// Generate JWT reset token to change password
 	resetToken, err := h.tokenManager.GenerateResetToken(req.Email)
 	if err != nil {
-		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, nil)
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
@@ -366,20 +417,783 @@ func (h *AuthHandler) ChangePassword(ctx *gin.Context) {
 		return
 	}
 
-	mail, err := h.tokenManager.ParseResetToken(req.ResetToken)
+	mail, err := h.tokenManager.ConsumeResetToken(req.ResetToken)
 	if err != nil {
-		// Todo: Better error handling
-		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		switch {
+		case errors.Is(err, errors.ErrResetTokenUsed):
+			response.WithError(ctx, http.StatusUnauthorized, messages.ResetTokenUsed, err)
+		case errors.Is(err, errors.ErrTokenExpired):
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenExpired, err)
+		default:
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		}
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(mail)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithError(ctx, http.StatusNotFound, messages.UserNotFound, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
 	err = h.userService.UpdatePassword(mail, req.Password)
 	if err != nil {
-		// Todo: Better error handling
-		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	// Sessions opened with the old password may belong to whoever made the reset necessary
+	err = h.tokenManager.RevokeRefreshTokens(user.Id)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	// Proving access to the email is enough to end a lockout
+	err = h.loginGuardService.Unlock(user.Email)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
 		return
 	}
 
 	response.WithSuccess(ctx, http.StatusOK, "Password change", nil)
 
 }
+
+// VerifyEmail only marks the email as verified, it doesn't sign the user in.
+// Clients refresh their session to get an access token with email_verified=true.
+func (h *AuthHandler) VerifyEmail(ctx *gin.Context) {
+	var req models.OTPVerificationRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	valid, err := h.otpService.VerifyOTP(req.Email, req.OTP, models.OTPPurposeEmailVerification)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrInvalidOTP):
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidOTP, err)
+		case errors.Is(err, errors.ErrOTPExpired):
+			response.WithError(ctx, http.StatusUnauthorized, messages.OTPExpired, err)
+		case errors.Is(err, errors.ErrOTPAttemptsExceeded):
+			response.WithError(ctx, http.StatusTooManyRequests, messages.OTPAttemptsExceeded, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	if !valid {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidOTP, errors.ErrInvalidOTP)
+		return
+	}
+
+	err = h.userService.MarkEmailVerified(req.Email)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.EmailVerified, nil)
+}
+
+// ResendVerification emails a new verification code. It responds the same whether the account exists,
+// is already verified or not, so it can't be used to find registered emails.
+func (h *AuthHandler) ResendVerification(ctx *gin.Context) {
+	var req models.OTPRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentVerificationCode, nil)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	if user.IsEmailVerified() {
+		response.WithSuccess(ctx, http.StatusOK, messages.SentVerificationCode, nil)
+		return
+	}
+
+	err = h.sendVerificationEmail(user.Email)
+	if err != nil {
+		// Only an existing account can be in cooldown, the code already sent is still valid
+		if errors.Is(err, errors.ErrOTPCooldown) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentVerificationCode, nil)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.SentVerificationCode, nil)
+}
+
+// UnlockAccount emails a code to unlock an account locked by failed logins.
+// It responds the same whether the account exists or not.
+func (h *AuthHandler) UnlockAccount(ctx *gin.Context) {
+	var req models.OTPRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentUnlockCode, nil)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	otpCode, err := h.otpService.GenerateOTP(user.Email, models.OTPPurposeAccountUnlock)
+	if err != nil {
+		// Only an existing account can be in cooldown, the code already sent is still valid
+		if errors.Is(err, errors.ErrOTPCooldown) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentUnlockCode, nil)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	err = h.mailManager.UnlockAccount(user.Email, otpCode)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.SentUnlockCode, nil)
+}
+
+func (h *AuthHandler) VerifyUnlock(ctx *gin.Context) {
+	var req models.UnlockVerificationRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	valid, err := h.otpService.VerifyOTP(req.Email, req.OTP, models.OTPPurposeAccountUnlock)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrInvalidOTP):
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidOTP, err)
+		case errors.Is(err, errors.ErrOTPExpired):
+			response.WithError(ctx, http.StatusUnauthorized, messages.OTPExpired, err)
+		case errors.Is(err, errors.ErrOTPAttemptsExceeded):
+			response.WithError(ctx, http.StatusTooManyRequests, messages.OTPAttemptsExceeded, err)
+		default:
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		}
+		return
+	}
+
+	if !valid {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidOTP, errors.ErrInvalidOTP)
+		return
+	}
+
+	err = h.loginGuardService.Unlock(req.Email)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.AccountUnlocked, nil)
+}
+
+func (h *AuthHandler) sendVerificationEmail(email string) error {
+	otpCode, err := h.otpService.GenerateOTP(email, models.OTPPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	return h.mailManager.VerifyEmail(email, otpCode)
+}
+
+func (h *AuthHandler) Sessions(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	sessions, err := h.tokenManager.Sessions(uid)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"sessions": sessions,
+	})
+}
+
+func (h *AuthHandler) RevokeSession(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	sessionId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		response.WithError(ctx, http.StatusNotFound, messages.SessionNotFound, errors.ErrNotFoundRefreshToken)
+		return
+	}
+
+	err = h.tokenManager.DeleteRefreshToken(uid, sessionId)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFoundRefreshToken) {
+			response.WithError(ctx, http.StatusNotFound, messages.SessionNotFound, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.SessionRevoked, nil)
+}
+
+// SendMagicLink emails a single-use login link. It responds the same whether the account exists or not.
+func (h *AuthHandler) SendMagicLink(ctx *gin.Context) {
+	var req models.OTPRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentMagicLink, nil)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	nonce, err := h.otpService.GenerateMagicLink(user.Email)
+	if err != nil {
+		// Only an existing account can be in cooldown, the link already sent is still valid
+		if errors.Is(err, errors.ErrOTPCooldown) {
+			response.WithSuccess(ctx, http.StatusOK, messages.SentMagicLink, nil)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	magicLinkToken, err := h.tokenManager.GenerateMagicLinkToken(user.Email, nonce)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	link, err := url.Parse(h.magicLinkURL)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	query := link.Query()
+	query.Set("token", magicLinkToken)
+	link.RawQuery = query.Encode()
+
+	err = h.mailManager.MagicLink(user.Email, link.String())
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.SentMagicLink, nil)
+}
+
+// VerifyMagicLink exchanges the token of a login link for the same response Login gives
+func (h *AuthHandler) VerifyMagicLink(ctx *gin.Context) {
+	var req models.MagicLinkVerificationRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	email, nonce, err := h.tokenManager.ParseMagicLinkToken(req.Token)
+	if err != nil {
+		if errors.Is(err, errors.ErrTokenExpired) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenExpired, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	err = h.otpService.ConsumeMagicLink(email, nonce)
+	if err != nil {
+		if errors.Is(err, errors.ErrMagicLinkUsed) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.MagicLinkUsed, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	// Opening the link proves the user owns the email
+	if !user.IsEmailVerified() {
+		if err := h.userService.MarkEmailVerified(user.Email); err != nil {
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+			return
+		}
+
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	h.completeLogin(ctx, user)
+}
+
+// completeLogin answers a login whose first factor succeeded, users with two-factor authentication
+// get an mfa token to exchange at /auth/mfa/verify instead of a session
+func (h *AuthHandler) completeLogin(ctx *gin.Context, user *models.User) {
+	mfaEnabled, err := h.mfaService.IsEnabled(user.Id)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	// The failed attempts are kept until the second factor is verified, otherwise knowing the password would reset the code guessing limit
+	if mfaEnabled {
+		mfaToken, err := h.tokenManager.GenerateMFAToken(user.Id)
+		if err != nil {
+			response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+			return
+		}
+
+		response.WithSuccess(ctx, http.StatusOK, messages.MFARequired, gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	err = h.loginGuardService.Unlock(user.Email)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	accessToken, err := h.tokenManager.GenerateAccessToken(user)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	refreshToken, err := h.tokenManager.GenerateRefreshToken(user.Id, deviceInfo(ctx))
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.SuccessfullyLoggedIn, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+// VerifyMFA finishes a login that was answered with mfa_required
+func (h *AuthHandler) VerifyMFA(ctx *gin.Context) {
+	var req models.MFAVerificationRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	uid, err := h.tokenManager.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		if errors.Is(err, errors.ErrTokenExpired) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.TokenExpired, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	user, err := h.userService.GetUserById(uid)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	// Wrong codes count as failed logins, so the lockout also limits guessing the second factor
+	lockedUntil, err := h.loginGuardService.Check(user.Email, ctx.ClientIP())
+	if err != nil {
+		if errors.Is(err, errors.ErrLoginLocked) {
+			ctx.Header("Retry-After", strconv.Itoa(int(time.Until(lockedUntil).Seconds())+1))
+			response.WithError(ctx, http.StatusTooManyRequests, messages.LoginLocked, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	err = h.mfaService.Verify(user.Id, req.Code)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidMFACode) {
+			if err := h.loginGuardService.RecordFailure(user.Email, ctx.ClientIP()); err != nil {
+				response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+				return
+			}
+
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidMFACode, err)
+			return
+		}
+
+		if errors.Is(err, errors.ErrMFANotEnrolled) {
+			response.WithError(ctx, http.StatusBadRequest, messages.MFANotEnrolled, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	err = h.loginGuardService.Unlock(user.Email)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	accessToken, err := h.tokenManager.GenerateAccessToken(user)
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	refreshToken, err := h.tokenManager.GenerateRefreshToken(user.Id, deviceInfo(ctx))
+	if err != nil {
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.SuccessfullyLoggedIn, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+// EnrollMFA returns a new secret to add to an authenticator app, it is enforced after ConfirmMFA
+func (h *AuthHandler) EnrollMFA(ctx *gin.Context) {
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	user, err := h.userService.GetUserById(uid)
+	if err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	secret, uri, err := h.mfaService.Enroll(user.Id, user.Email)
+	if err != nil {
+		if errors.Is(err, errors.ErrMFAAlreadyEnabled) {
+			response.WithError(ctx, http.StatusConflict, messages.MFAAlreadyEnabled, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, "", gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+func (h *AuthHandler) ConfirmMFA(ctx *gin.Context) {
+	var req models.MFACodeRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	recoveryCodes, err := h.mfaService.Confirm(uid, req.Code)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidMFACode) {
+			response.WithError(ctx, http.StatusBadRequest, messages.InvalidMFACode, err)
+			return
+		}
+
+		if errors.Is(err, errors.ErrMFANotEnrolled) {
+			response.WithError(ctx, http.StatusBadRequest, messages.MFANotEnrolled, err)
+			return
+		}
+
+		if errors.Is(err, errors.ErrMFAAlreadyEnabled) {
+			response.WithError(ctx, http.StatusConflict, messages.MFAAlreadyEnabled, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.MFAEnabled, gin.H{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func (h *AuthHandler) DisableMFA(ctx *gin.Context) {
+	var req models.MFACodeRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			if len(validationErrors) > 0 {
+				fe := validationErrors[0]
+				var params []string
+				switch fe.Tag() {
+				case "min", "max":
+					params = []string{fe.Param()}
+				}
+				msg := messages.ErrorMessage{
+					Message: messages.MessageForTag(fe.Tag(), params...),
+				}
+				response.WithError(ctx, http.StatusBadRequest, msg.Message, fe)
+				return
+			}
+		}
+		response.WithError(ctx, http.StatusBadRequest, messages.InvalidJSONOrMissingFields, err)
+		return
+	}
+
+	uid, err := uuid.Parse(ctx.GetString("uid"))
+	if err != nil {
+		response.WithError(ctx, http.StatusUnauthorized, messages.InvalidToken, err)
+		return
+	}
+
+	err = h.mfaService.Disable(uid, req.Code)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidMFACode) {
+			response.WithError(ctx, http.StatusBadRequest, messages.InvalidMFACode, err)
+			return
+		}
+
+		if errors.Is(err, errors.ErrMFANotEnrolled) {
+			response.WithError(ctx, http.StatusBadRequest, messages.MFANotEnrolled, err)
+			return
+		}
+
+		response.WithError(ctx, http.StatusInternalServerError, messages.SomethingWentWrong, err)
+		return
+	}
+
+	response.WithSuccess(ctx, http.StatusOK, messages.MFADisabled, nil)
+}
+
+// JWKS is served as a plain JSON Web Key Set instead of the response envelope, JWT libraries expect that format
+func (h *AuthHandler) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.tokenManager.JWKS())
+}
+
+// maxUserAgentLength matches refresh_tokens.user_agent
+const maxUserAgentLength = 512
+
+func deviceInfo(ctx *gin.Context) models.DeviceInfo {
+	userAgent := ctx.Request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	os, browser, device := utils.ParseUserAgent(userAgent)
+
+	return models.DeviceInfo{
+		IP:        ctx.ClientIP(),
+		UserAgent: userAgent,
+		OS:        os,
+		Browser:   browser,
+		Device:    device,
+	}
+}
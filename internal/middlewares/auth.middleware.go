@@ -1,10 +1,11 @@
 package middlewares
 
 import (
-	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/token"
 	"github.com/gin-gonic/gin"
 )
@@ -58,9 +59,54 @@ func (m *AuthMiddleware) AccessToken() gin.HandlerFunc {
 
 		c.Set("uid", claims.Subject)
 		c.Set("role", claims.Role)
+		c.Set("permissions", claims.Permissions)
+		c.Set("email_verified", claims.EmailVerified)
 
-		fmt.Println(c.Get("uid"))
-		fmt.Println(c.Get("role"))
+		c.Next()
+	}
+}
+
+// RequireRole must be used after AccessToken, it rejects users whose role is not one of roles with 403
+func (m *AuthMiddleware) RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		userRole, ok := role.(models.Role)
+		if !ok || !slices.Contains(roles, userRole) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission must be used after AccessToken, it rejects users missing any of permissions with 403
+func (m *AuthMiddleware) RequirePermission(permissions ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, _ := c.Get("permissions")
+		userPermissions, _ := granted.([]models.Permission)
+
+		for _, permission := range permissions {
+			if !slices.Contains(userPermissions, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail must be used after AccessToken, it rejects users who haven't verified their email with 403
+func (m *AuthMiddleware) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// HotelCursor points right after the last hotel of a page in cursor pagination.
+// It is handed to clients as an opaque string.
+type HotelCursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	Id        string `json:"i"`
+}
+
+func (c HotelCursor) Encode() string {
+	doc, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(doc)
+}
+
+func DecodeHotelCursor(cursor string) (HotelCursor, error) {
+	var c HotelCursor
+
+	doc, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, errors.ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(doc, &c); err != nil {
+		return c, errors.ErrInvalidCursor
+	}
+
+	if _, err := uuid.Parse(c.Id); err != nil {
+		return c, errors.ErrInvalidCursor
+	}
+
+	return c, nil
+}
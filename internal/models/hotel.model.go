@@ -1,24 +1,29 @@
 package models
 
 import (
+	"fmt"
 	"math"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
 	"github.com/google/uuid"
 )
 
 type Hotel struct {
-	Id            uuid.UUID `json:"id" `
-	Name          string    `json:"name"`
-	Description   string    `json:"description"`
-	Location      Location  `json:"location"`
-	ImageUrl      string    `json:"image_url"`
-	PricePerNight string    `json:"price_per_night"`
-	PhoneNumber   string    `json:"phone_number"`
-	Rating        float64   `json:"rating"`
-	Features      []string  `json:"features"`
-	CreatedAt     string    `json:"created_at"`
+	Id            uuid.UUID  `json:"id" `
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Location      Location   `json:"location"`
+	ImageUrl      string     `json:"image_url"`
+	PricePerNight string     `json:"price_per_night"`
+	PhoneNumber   string     `json:"phone_number"`
+	Rating        float64    `json:"rating"`
+	Features      []string   `json:"features"`
+	CreatedAt     string     `json:"created_at"`
+	RoomTypes     []RoomType `json:"room_types,omitempty"`
 }
 
 type Location struct {
@@ -26,6 +31,151 @@ type Location struct {
 	Country string `json:"country"`
 }
 
+// HotelRequest is used by admins to create or replace a hotel, the rules mirror the CHECK constraints of the hotels table
+type HotelRequest struct {
+	Name          string          `json:"name" binding:"required,min=3,max=255"`
+	Description   string          `json:"description" binding:"required,min=10,max=255"`
+	Location      LocationRequest `json:"location" binding:"required"`
+	ImageUrl      string          `json:"image_url" binding:"required,url,min=10,max=255"`
+	PricePerNight float64         `json:"price_per_night" binding:"required,gt=0,lt=100000000"`
+	PhoneNumber   string          `json:"phone_number" binding:"required,max=20"`
+	Rating        *float64        `json:"rating" binding:"required,min=0,max=5"`
+	Features      []string        `json:"features" binding:"dive,min=2,max=100,excludesall=0x2C"`
+}
+
+type LocationRequest struct {
+	City    string `json:"city" binding:"required,min=3,max=50"`
+	Country string `json:"country" binding:"required,min=3,max=50"`
+}
+
+// HotelPatchRequest updates only the fields that are present
+type HotelPatchRequest struct {
+	Name          *string   `json:"name" binding:"omitempty,min=3,max=255"`
+	Description   *string   `json:"description" binding:"omitempty,min=10,max=255"`
+	City          *string   `json:"city" binding:"omitempty,min=3,max=50"`
+	Country       *string   `json:"country" binding:"omitempty,min=3,max=50"`
+	ImageUrl      *string   `json:"image_url" binding:"omitempty,url,min=10,max=255"`
+	PricePerNight *float64  `json:"price_per_night" binding:"omitempty,gt=0,lt=100000000"`
+	PhoneNumber   *string   `json:"phone_number" binding:"omitempty,max=20"`
+	Rating        *float64  `json:"rating" binding:"omitempty,min=0,max=5"`
+	Features      *[]string `json:"features" binding:"omitempty,dive,min=2,max=100,excludesall=0x2C"`
+}
+
+// Apply overrides the fields of req that are set in the patch
+func (p HotelPatchRequest) Apply(req *HotelRequest) {
+	if p.Name != nil {
+		req.Name = *p.Name
+	}
+	if p.Description != nil {
+		req.Description = *p.Description
+	}
+	if p.City != nil {
+		req.Location.City = *p.City
+	}
+	if p.Country != nil {
+		req.Location.Country = *p.Country
+	}
+	if p.ImageUrl != nil {
+		req.ImageUrl = *p.ImageUrl
+	}
+	if p.PricePerNight != nil {
+		req.PricePerNight = *p.PricePerNight
+	}
+	if p.PhoneNumber != nil {
+		req.PhoneNumber = *p.PhoneNumber
+	}
+	if p.Rating != nil {
+		req.Rating = p.Rating
+	}
+	if p.Features != nil {
+		req.Features = *p.Features
+	}
+}
+
+// ManagerHotelRequest is used by hotel managers to replace their hotel, it leaves out the fields only admins may set
+type ManagerHotelRequest struct {
+	Name          string          `json:"name" binding:"required,min=3,max=255"`
+	Description   string          `json:"description" binding:"required,min=10,max=255"`
+	Location      LocationRequest `json:"location" binding:"required"`
+	ImageUrl      string          `json:"image_url" binding:"required,url,min=10,max=255"`
+	PricePerNight float64         `json:"price_per_night" binding:"required,gt=0,lt=100000000"`
+	PhoneNumber   string          `json:"phone_number" binding:"required,max=20"`
+	Features      []string        `json:"features" binding:"dive,min=2,max=100,excludesall=0x2C"`
+}
+
+// ToPatch converts the request to a patch that sets every field a manager owns and keeps the others
+func (r ManagerHotelRequest) ToPatch() HotelPatchRequest {
+	return HotelPatchRequest{
+		Name:          &r.Name,
+		Description:   &r.Description,
+		City:          &r.Location.City,
+		Country:       &r.Location.Country,
+		ImageUrl:      &r.ImageUrl,
+		PricePerNight: &r.PricePerNight,
+		PhoneNumber:   &r.PhoneNumber,
+		Features:      &r.Features,
+	}
+}
+
+// ManagerHotelPatchRequest is HotelPatchRequest without the fields only admins may set
+type ManagerHotelPatchRequest struct {
+	Name          *string   `json:"name" binding:"omitempty,min=3,max=255"`
+	Description   *string   `json:"description" binding:"omitempty,min=10,max=255"`
+	City          *string   `json:"city" binding:"omitempty,min=3,max=50"`
+	Country       *string   `json:"country" binding:"omitempty,min=3,max=50"`
+	ImageUrl      *string   `json:"image_url" binding:"omitempty,url,min=10,max=255"`
+	PricePerNight *float64  `json:"price_per_night" binding:"omitempty,gt=0,lt=100000000"`
+	PhoneNumber   *string   `json:"phone_number" binding:"omitempty,max=20"`
+	Features      *[]string `json:"features" binding:"omitempty,dive,min=2,max=100,excludesall=0x2C"`
+}
+
+func (p ManagerHotelPatchRequest) ToPatch() HotelPatchRequest {
+	return HotelPatchRequest{
+		Name:          p.Name,
+		Description:   p.Description,
+		City:          p.City,
+		Country:       p.Country,
+		ImageUrl:      p.ImageUrl,
+		PricePerNight: p.PricePerNight,
+		PhoneNumber:   p.PhoneNumber,
+		Features:      p.Features,
+	}
+}
+
+// ToRequest converts hotel to the request that would replace it with itself
+func (h Hotel) ToRequest() (HotelRequest, error) {
+	price, err := strconv.ParseFloat(h.PricePerNight, 64)
+	if err != nil {
+		return HotelRequest{}, fmt.Errorf("parse price per night: %w", err)
+	}
+
+	rating := h.Rating
+
+	return HotelRequest{
+		Name:          h.Name,
+		Description:   h.Description,
+		Location:      LocationRequest{City: h.Location.City, Country: h.Location.Country},
+		ImageUrl:      h.ImageUrl,
+		PricePerNight: price,
+		PhoneNumber:   h.PhoneNumber,
+		Rating:        &rating,
+		Features:      h.Features,
+	}, nil
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// HotelFacets holds the number of hotels per filter value, ratings are bucketed by their integer part
+type HotelFacets struct {
+	Cities    []FacetCount `json:"cities"`
+	Countries []FacetCount `json:"countries"`
+	Features  []FacetCount `json:"features"`
+	Ratings   []FacetCount `json:"ratings"`
+}
+
 type HotelFilterParams struct {
 	Page      int      `json:"page" form:"page"`
 	PageSize  int      `json:"pageSize" form:"pageSize" `
@@ -38,8 +188,25 @@ type HotelFilterParams struct {
 	MinRating float64  `json:"minRating" form:"minRating"`
 	Features  []string `json:"features" form:"features"`
 	Search    string   `json:"search" form:"search"`
+	CheckIn   string   `json:"checkIn" form:"checkIn"`
+	CheckOut  string   `json:"checkOut" form:"checkOut"`
+	Guests    int      `json:"guests" form:"guests"`
+	Rooms     int      `json:"rooms" form:"rooms"`
+	// FeatureMatch is either "all" (default) or "any"
+	FeatureMatch string `json:"featureMatch" form:"featureMatch"`
+	// Pagination is either "offset" (default) or "cursor"
+	Pagination string `json:"pagination" form:"pagination"`
+	Cursor     string `json:"cursor" form:"cursor"`
 }
 
+const (
+	FeatureMatchAll = "all"
+	FeatureMatchAny = "any"
+)
+
+// Longest stay that can be searched for availability
+const maxSearchNights = 30
+
 func (p *HotelFilterParams) Validate() {
 	if p.Page <= 0 {
 		p.Page = 1
@@ -60,6 +227,75 @@ func (p *HotelFilterParams) Validate() {
 	if p.MaxPrice == 0 {
 		p.MaxPrice = math.MaxInt
 	}
+
+	if p.FeatureMatch != FeatureMatchAny {
+		p.FeatureMatch = FeatureMatchAll
+	}
+
+	if p.Guests <= 0 {
+		p.Guests = 1
+	}
+
+	if p.Rooms <= 0 {
+		p.Rooms = 1
+	}
+}
+
+// ValidateStay checks the optional checkIn/checkOut pair, both must be given together
+func (p *HotelFilterParams) ValidateStay() error {
+	if p.CheckIn == "" && p.CheckOut == "" {
+		return nil
+	}
+
+	checkIn, err := time.Parse(dateLayout, p.CheckIn)
+	if err != nil {
+		return errors.ErrInvalidStayDates
+	}
+
+	checkOut, err := time.Parse(dateLayout, p.CheckOut)
+	if err != nil {
+		return errors.ErrInvalidStayDates
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	nights := Nights(checkIn, checkOut)
+	if nights <= 0 || nights > maxSearchNights || checkIn.Before(today) {
+		return errors.ErrInvalidStayDates
+	}
+
+	return nil
+}
+
+// UsesCursor reports whether keyset pagination is requested instead of page numbers
+func (p HotelFilterParams) UsesCursor() bool {
+	return p.Cursor != "" || p.Pagination == "cursor"
+}
+
+// HasStay reports whether the search is restricted to a stay
+func (p HotelFilterParams) HasStay() bool {
+	return p.CheckIn != "" && p.CheckOut != ""
+}
+
+// StayNights returns every night of the stay formatted as a date, ValidateStay must be called first
+func (p HotelFilterParams) StayNights() []string {
+	if !p.HasStay() {
+		return nil
+	}
+
+	checkIn, _ := time.Parse(dateLayout, p.CheckIn)
+	checkOut, _ := time.Parse(dateLayout, p.CheckOut)
+
+	var nights []string
+	for night := checkIn; night.Before(checkOut); night = night.AddDate(0, 0, 1) {
+		nights = append(nights, night.Format(dateLayout))
+	}
+
+	return nights
+}
+
+// MinRoomCapacity is the capacity each room needs to fit the guests into the requested number of rooms
+func (p HotelFilterParams) MinRoomCapacity() int {
+	return (p.Guests + p.Rooms - 1) / p.Rooms
 }
 
 func (p *HotelFilterParams) NormalizeFeatures() {
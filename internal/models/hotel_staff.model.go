@@ -0,0 +1,5 @@
+package models
+
+type HotelStaffRequest struct {
+	UserId string `json:"user_id" binding:"required,uuid"`
+}
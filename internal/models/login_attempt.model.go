@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type LoginScope string
+
+const (
+	LoginScopeAccount LoginScope = "account"
+	LoginScopeIP      LoginScope = "ip"
+)
+
+// LoginAttempt counts the consecutive failed logins of an email or an IP address
+type LoginAttempt struct {
+	Scope        LoginScope
+	Subject      string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+type UnlockVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	OTP   string `json:"otp" binding:"required,min=6,max=6"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA is the TOTP enrollment of a user, it is only enforced once ConfirmedAt is set
+type UserMFA struct {
+	UserId       uuid.UUID
+	Secret       string
+	LastUsedStep int64
+	ConfirmedAt  *time.Time
+	CreatedAt    time.Time
+}
+
+func (m UserMFA) IsConfirmed() bool {
+	return m.ConfirmedAt != nil
+}
+
+// MFACodeRequest accepts either a TOTP code or a recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,min=6,max=20"`
+}
+
+type MFAVerificationRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required,min=6,max=20"`
+}
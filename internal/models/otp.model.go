@@ -6,12 +6,24 @@ import (
 	"github.com/google/uuid"
 )
 
+// OTPPurpose keeps a code sent for one flow from being accepted by another
+type OTPPurpose string
+
+const (
+	OTPPurposePasswordReset     OTPPurpose = "password_reset"
+	OTPPurposeEmailVerification OTPPurpose = "email_verification"
+	OTPPurposeAccountUnlock     OTPPurpose = "account_unlock"
+	OTPPurposeMagicLink         OTPPurpose = "magic_link"
+)
+
 type OTPToken struct {
 	Id        uuid.UUID
 	Email     string
 	TokenHash string
 	ExpiresAt time.Time
 	CreatedAt time.Time
+	Attempts  int
+	Purpose   OTPPurpose
 }
 
 type OTPRequest struct {
@@ -22,3 +34,7 @@ type OTPVerificationRequest struct {
 	Email string `json:"email" binding:"required,email"`
 	OTP   string `json:"otp" binding:"required,min=6,max=6"`
 }
+
+type MagicLinkVerificationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,19 @@
+package models
+
+type Permission string
+
+const (
+	PermissionManageHotels       Permission = "hotels:manage"
+	PermissionReadReservations   Permission = "reservations:read"
+	PermissionRefundReservations Permission = "reservations:refund"
+)
+
+// DefaultRolePermissions is seeded into role_permissions at startup,
+// grants added to the table later are kept.
+// Hotel managers have no global permissions, they are checked against hotel_staff instead
+var DefaultRolePermissions = map[Role][]Permission{
+	RoleAdmin:        {PermissionManageHotels, PermissionReadReservations, PermissionRefundReservations},
+	RoleSupport:      {PermissionReadReservations},
+	RoleHotelManager: {},
+	RoleUser:         {},
+}
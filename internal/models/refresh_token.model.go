@@ -6,20 +6,27 @@ import (
 	"github.com/google/uuid"
 )
 
+// RefreshToken is a single login session, a user has one per device.
+// Every refresh rotates the token, the rotated tokens stay in the same family until they expire
 type RefreshToken struct {
-	Id        uuid.UUID `json:"id"`
-	UserId    uuid.UUID `json:"user_id"`
-	TokenHash string    `json:"-"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	// DeviceInfo DeviceInfo
+	Id         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"user_id"`
+	FamilyId   uuid.UUID  `json:"-"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"-"`
+	DeviceInfo DeviceInfo `json:"device"`
+}
+
+func (t RefreshToken) IsRotated() bool {
+	return t.RotatedAt != nil
 }
 
-// Kullanıcının farklı cihazlardan açtığı oturumlar takip edilmek istenirse implement edilebilir
 type DeviceInfo struct {
-	IP        string
-	UserAgent string
-	OS        string
-	Browser   string
-	Device    string
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	OS        string `json:"os"`
+	Browser   string `json:"browser"`
+	Device    string `json:"device"`
 }
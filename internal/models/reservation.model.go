@@ -1,14 +1,59 @@
 package models
 
-// TODO: Status enum  => pending, paid
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const dateLayout = "2006-01-02"
+
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "pending"
+	ReservationPaid      ReservationStatus = "paid"
+	ReservationCancelled ReservationStatus = "cancelled"
+)
 
 type Reservation struct {
-	Id           string `json:"id"`
-	UserId       string `json:"user_id"`
-	HotelId      string `json:"hotel_id"`
-	CheckInDate  string `json:"check_in_date"`
-	CheckOutDate string `json:"check_out_date"`
-	GuestCount   int    `json:"guest_count"`
-	TotalPrice   int    `json:"total_price"`
-	Status       string `json:"status"`
+	Id           uuid.UUID         `json:"id"`
+	UserId       uuid.UUID         `json:"user_id"`
+	HotelId      uuid.UUID         `json:"hotel_id"`
+	RoomTypeId   uuid.UUID         `json:"room_type_id"`
+	CheckInDate  time.Time         `json:"check_in_date"`
+	CheckOutDate time.Time         `json:"check_out_date"`
+	GuestCount   int               `json:"guest_count"`
+	TotalPrice   float64           `json:"total_price"`
+	Status       ReservationStatus `json:"status"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+type ReservationRequest struct {
+	HotelId      string `json:"hotel_id" binding:"required,uuid"`
+	RoomTypeId   string `json:"room_type_id" binding:"required,uuid"`
+	CheckInDate  string `json:"check_in_date" binding:"required,datetime=2006-01-02"`
+	CheckOutDate string `json:"check_out_date" binding:"required,datetime=2006-01-02"`
+	GuestCount   int    `json:"guest_count" binding:"required,min=1,max=20"`
+}
+
+// StayDates parses the check-in and check-out dates of the request
+func (r ReservationRequest) StayDates() (time.Time, time.Time, error) {
+	checkIn, err := time.Parse(dateLayout, r.CheckInDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse check in date: %w", err)
+	}
+
+	checkOut, err := time.Parse(dateLayout, r.CheckOutDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parse check out date: %w", err)
+	}
+
+	return checkIn, checkOut, nil
+}
+
+// Nights returns the number of nights between check-in and check-out
+func Nights(checkIn, checkOut time.Time) int {
+	return int(checkOut.Sub(checkIn).Hours() / 24)
 }
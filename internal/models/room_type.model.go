@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RoomType struct {
+	Id               uuid.UUID `json:"id"`
+	HotelId          uuid.UUID `json:"hotel_id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Capacity         int       `json:"capacity"`
+	BedConfiguration string    `json:"bed_configuration"`
+	BasePrice        string    `json:"base_price"`
+	UnitCount        int       `json:"unit_count"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type RoomTypeRequest struct {
+	Name             string  `json:"name" binding:"required,min=3,max=100"`
+	Description      string  `json:"description" binding:"required,max=255"`
+	Capacity         int     `json:"capacity" binding:"required,min=1,max=20"`
+	BedConfiguration string  `json:"bed_configuration" binding:"required,max=100"`
+	BasePrice        float64 `json:"base_price" binding:"required,gt=0"`
+	UnitCount        int     `json:"unit_count" binding:"min=0"`
+}
@@ -9,13 +9,15 @@ import (
 type Role string
 
 const (
-	RoleAdmin Role = "admin"
-	RoleUser  Role = "user"
+	RoleAdmin        Role = "admin"
+	RoleUser         Role = "user"
+	RoleSupport      Role = "support"
+	RoleHotelManager Role = "hotel_manager"
 )
 
 func (r Role) IsValid() bool {
 	switch r {
-	case RoleAdmin, RoleUser:
+	case RoleAdmin, RoleUser, RoleSupport, RoleHotelManager:
 		return true
 	default:
 		return false
@@ -29,8 +31,14 @@ type User struct {
 	Name         string    `json:"name" validate:"required,min=3,max=50"`
 	Email        string    `json:"email" validate:"required,email"`
 	PasswordHash string    `json:"-" validate:"required,min=8"`
-	Role         Role      `json:"role" validate:"required,oneof=admin user"`
+	Role         Role      `json:"role" validate:"required,oneof=admin user support hotel_manager"`
 	CreatedAt    time.Time `json:"created_at" validate:"required"`
+	// Nil until the user enters the code sent to their email
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+func (u User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // TODO: request'i düzenlemek için method yaz Trimspace lower
@@ -0,0 +1,8 @@
+package models
+
+// OIDCCallbackRequest carries what the identity provider appended to the redirect URL.
+// The client should check that State is the one it got from /auth/oidc/:provider before sending it.
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
@@ -3,28 +3,45 @@ package routes
 import (
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/handlers"
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/middlewares"
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
 	"github.com/gin-gonic/gin"
 )
 
 type Manager struct {
-	r              *gin.RouterGroup
-	authMiddleware *middlewares.AuthMiddleware
-	authHandler    *handlers.AuthHandler
-	hotelHandler   *handlers.HotelHandler
+	r                  *gin.RouterGroup
+	authMiddleware     *middlewares.AuthMiddleware
+	authHandler        *handlers.AuthHandler
+	hotelHandler       *handlers.HotelHandler
+	reservationHandler *handlers.ReservationHandler
+	roomTypeHandler    *handlers.RoomTypeHandler
+	managerHandler     *handlers.ManagerHandler
+	oidcHandler        *handlers.OIDCHandler
 }
 
-func NewManager(r *gin.RouterGroup, authHandler *handlers.AuthHandler, hotelHandler *handlers.HotelHandler, authMiddleware *middlewares.AuthMiddleware) *Manager {
+func NewManager(r *gin.RouterGroup, authHandler *handlers.AuthHandler, hotelHandler *handlers.HotelHandler, reservationHandler *handlers.ReservationHandler, roomTypeHandler *handlers.RoomTypeHandler, managerHandler *handlers.ManagerHandler, oidcHandler *handlers.OIDCHandler, authMiddleware *middlewares.AuthMiddleware) *Manager {
 	return &Manager{
-		r:              r,
-		authMiddleware: authMiddleware,
-		authHandler:    authHandler,
-		hotelHandler:   hotelHandler,
+		r:                  r,
+		authMiddleware:     authMiddleware,
+		authHandler:        authHandler,
+		hotelHandler:       hotelHandler,
+		reservationHandler: reservationHandler,
+		roomTypeHandler:    roomTypeHandler,
+		managerHandler:     managerHandler,
+		oidcHandler:        oidcHandler,
 	}
 }
 
 func (m *Manager) SetupRoutes() {
 	m.authRoutes()
 	m.hotelRoutes()
+	m.reservationRoutes()
+	m.adminRoutes()
+	m.manageRoutes()
+}
+
+// SetupWellKnownRoutes registers the routes that have to be served from the root instead of /api
+func (m *Manager) SetupWellKnownRoutes(root *gin.Engine) {
+	root.GET("/.well-known/jwks.json", m.authHandler.JWKS)
 }
 
 func (m Manager) authRoutes() {
@@ -39,7 +56,27 @@ func (m Manager) authRoutes() {
 		auth.POST("/forgot-password", m.authHandler.ForgotPassword)
 		auth.POST("/verify-otp", m.authHandler.VerifyOTP)
 
+		auth.POST("/verify-email", m.authHandler.VerifyEmail)
+		auth.POST("/resend-verification", m.authHandler.ResendVerification)
+
+		auth.POST("/unlock-account", m.authHandler.UnlockAccount)
+		auth.POST("/verify-unlock", m.authHandler.VerifyUnlock)
+
+		auth.POST("/magic-link", m.authHandler.SendMagicLink)
+		auth.POST("/magic-link/verify", m.authHandler.VerifyMagicLink)
+
+		auth.GET("/oidc/:provider", m.oidcHandler.AuthURL)
+		auth.POST("/oidc/:provider/callback", m.oidcHandler.Callback)
+
+		auth.POST("/mfa/verify", m.authHandler.VerifyMFA)
+		auth.POST("/mfa/enroll", m.authMiddleware.AccessToken(), m.authHandler.EnrollMFA)
+		auth.POST("/mfa/confirm", m.authMiddleware.AccessToken(), m.authHandler.ConfirmMFA)
+		auth.POST("/mfa/disable", m.authMiddleware.AccessToken(), m.authHandler.DisableMFA)
+
 		auth.GET("/test", m.authMiddleware.AccessToken())
+
+		auth.GET("/sessions", m.authMiddleware.AccessToken(), m.authHandler.Sessions)
+		auth.DELETE("/sessions/:id", m.authMiddleware.AccessToken(), m.authHandler.RevokeSession)
 	}
 }
 
@@ -48,6 +85,54 @@ func (m Manager) hotelRoutes() {
 
 	{
 		hotel.GET("/", m.hotelHandler.Hotels)
+		hotel.GET("/facets", m.hotelHandler.Facets)
 		hotel.GET("/:id", m.hotelHandler.Hotel)
 	}
 }
+
+func (m Manager) reservationRoutes() {
+	reservation := m.r.Group("/reservations", m.authMiddleware.AccessToken())
+
+	{
+		reservation.POST("/", m.authMiddleware.RequireVerifiedEmail(), m.reservationHandler.Create)
+		reservation.GET("/", m.reservationHandler.Reservations)
+		reservation.GET("/:id", m.reservationHandler.Reservation)
+		reservation.POST("/:id/cancel", m.reservationHandler.Cancel)
+	}
+}
+
+func (m Manager) adminRoutes() {
+	admin := m.r.Group("/admin", m.authMiddleware.AccessToken())
+
+	hotel := admin.Group("/hotels", m.authMiddleware.RequirePermission(models.PermissionManageHotels))
+	{
+		hotel.POST("/", m.hotelHandler.Create)
+		hotel.PUT("/:id", m.hotelHandler.Update)
+		hotel.PATCH("/:id", m.hotelHandler.Patch)
+		hotel.DELETE("/:id", m.hotelHandler.Delete)
+
+		hotel.POST("/:id/room-types", m.roomTypeHandler.Create)
+		hotel.PUT("/:id/room-types/:roomTypeId", m.roomTypeHandler.Update)
+		hotel.DELETE("/:id/room-types/:roomTypeId", m.roomTypeHandler.Delete)
+
+		hotel.POST("/:id/staff", m.managerHandler.AssignStaff)
+		hotel.DELETE("/:id/staff/:userId", m.managerHandler.RemoveStaff)
+	}
+
+	reservation := admin.Group("/reservations")
+	{
+		reservation.GET("/", m.authMiddleware.RequirePermission(models.PermissionReadReservations), m.reservationHandler.AllReservations)
+		reservation.POST("/:id/cancel", m.authMiddleware.RequirePermission(models.PermissionRefundReservations), m.reservationHandler.CancelAny)
+	}
+}
+
+// manageRoutes are scoped to the hotels the manager is assigned to, the services check hotel_staff
+func (m Manager) manageRoutes() {
+	hotel := m.r.Group("/manage/hotels", m.authMiddleware.AccessToken(), m.authMiddleware.RequireRole(models.RoleHotelManager))
+	{
+		hotel.GET("/", m.managerHandler.Hotels)
+		hotel.PUT("/:id", m.managerHandler.Update)
+		hotel.PATCH("/:id", m.managerHandler.Patch)
+		hotel.GET("/:id/reservations", m.managerHandler.Reservations)
+	}
+}
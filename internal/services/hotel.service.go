@@ -1,12 +1,18 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
 	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/db"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/google/uuid"
 )
 
 type HotelService struct {
@@ -19,15 +25,127 @@ func NewHotelService(db *sql.DB) *HotelService {
 	}
 }
 
-func (hs *HotelService) GetHotels(params models.HotelFilterParams) ([]models.Hotel, error) {
-	query := queries.BuildHotelsQueryWithParams(params)
+// GetHotels returns the requested page of hotels matching params and the total number of matches
+func (hs *HotelService) GetHotels(params models.HotelFilterParams) ([]models.Hotel, int, error) {
+	var total int
+	countQuery, countArgs := queries.BuildHotelsCountQueryWithParams(params)
+	if err := hs.db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count hotels: %w", err)
+	}
+
+	query, args := queries.BuildHotelsQueryWithParams(params)
+
+	rows, err := hs.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get hotels: %w", err)
+	}
+
+	hotels, err := scanHotels(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hotels, total, nil
+}
+
+// GetHotelsByCursor returns the page of hotels after cursor and the cursor of the next page, empty on the last page
+func (hs *HotelService) GetHotelsByCursor(params models.HotelFilterParams) ([]models.Hotel, string, error) {
+	var cursor *models.HotelCursor
+	if params.Cursor != "" {
+		c, err := models.DecodeHotelCursor(params.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// A cursor is only meaningful for the sort order it was created with
+		if c.SortBy != queries.SortColumn(params.SortBy) || c.SortOrder != queries.SortDirection(params.SortOrder) {
+			return nil, "", errors.ErrInvalidCursor
+		}
+
+		cursor = &c
+	}
+
+	query, args, err := queries.BuildHotelsCursorQueryWithParams(params, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := hs.db.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get hotels: %w", err)
+	}
+
+	hotels, err := scanHotels(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(hotels) <= params.PageSize {
+		return hotels, "", nil
+	}
+
+	hotels = hotels[:params.PageSize]
+	last := hotels[len(hotels)-1]
+	column := queries.SortColumn(params.SortBy)
+
+	next := models.HotelCursor{
+		SortBy:    column,
+		SortOrder: queries.SortDirection(params.SortOrder),
+		Value:     hotelSortValue(last, column),
+		Id:        last.Id.String(),
+	}
+
+	return hotels, next.Encode(), nil
+}
 
-	rows, err := hs.db.Query(query)
+func (hs *HotelService) GetHotelFacets(params models.HotelFilterParams) (models.HotelFacets, error) {
+	var facets models.HotelFacets
+	var err error
+
+	if facets.Cities, err = hs.facetCounts(params, queries.FacetCity); err != nil {
+		return facets, fmt.Errorf("get city facets: %w", err)
+	}
+
+	if facets.Countries, err = hs.facetCounts(params, queries.FacetCountry); err != nil {
+		return facets, fmt.Errorf("get country facets: %w", err)
+	}
+
+	if facets.Features, err = hs.facetCounts(params, queries.FacetFeature); err != nil {
+		return facets, fmt.Errorf("get feature facets: %w", err)
+	}
+
+	if facets.Ratings, err = hs.facetCounts(params, queries.FacetRating); err != nil {
+		return facets, fmt.Errorf("get rating facets: %w", err)
+	}
+
+	return facets, nil
+}
+
+func (hs *HotelService) facetCounts(params models.HotelFilterParams, facet queries.Facet) ([]models.FacetCount, error) {
+	query, args := queries.BuildHotelFacetQueryWithParams(params, facet)
+
+	rows, err := hs.db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get hotels: %w", err)
+		return nil, err
 	}
 
-	var hotels []models.Hotel
+	defer rows.Close()
+
+	counts := []models.FacetCount{}
+	for rows.Next() {
+		var count models.FacetCount
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+func scanHotels(rows *sql.Rows) ([]models.Hotel, error) {
+	hotels := []models.Hotel{}
 
 	defer rows.Close()
 
@@ -50,18 +168,44 @@ func (hs *HotelService) GetHotels(params models.HotelFilterParams) ([]models.Hot
 		if err != nil {
 			return hotels, fmt.Errorf("failed to scan hotel: %w", err)
 		}
-		hotel.Features = strings.Split(features, ",")
+		hotel.Features = splitFeatures(features)
 
 		hotels = append(hotels, hotel)
 	}
 
-	if rows.Err() != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("get all hotels with filter: %w", err)
 	}
 
 	return hotels, nil
 }
 
+func splitFeatures(features string) []string {
+	if features == "" {
+		return []string{}
+	}
+
+	return strings.Split(features, ",")
+}
+
+// hotelSortValue returns the value of the sort column of hotel as it is stored in a cursor
+func hotelSortValue(hotel models.Hotel, column string) string {
+	switch column {
+	case "city":
+		return hotel.Location.City
+	case "country":
+		return hotel.Location.Country
+	case "price_per_night":
+		return hotel.PricePerNight
+	case "rating":
+		return strconv.FormatFloat(hotel.Rating, 'f', -1, 64)
+	case "created_at":
+		return hotel.CreatedAt
+	default:
+		return hotel.Name
+	}
+}
+
 func (hs *HotelService) GetHotelById(hotelId string) (models.Hotel, error) {
 	var hotel models.Hotel
 	var features string
@@ -78,10 +222,175 @@ func (hs *HotelService) GetHotelById(hotelId string) (models.Hotel, error) {
 		&features,
 		&hotel.CreatedAt)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Hotel{}, errors.ErrHotelNotFound
+		}
 		return models.Hotel{}, fmt.Errorf("get hotel by id: %w", err)
 	}
 
-	hotel.Features = strings.Split(features, ",")
+	hotel.Features = splitFeatures(features)
 
 	return hotel, nil
 }
+
+func (hs *HotelService) CreateHotel(req models.HotelRequest) (models.Hotel, error) {
+	id := uuid.New()
+
+	tx, err := hs.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return models.Hotel{}, fmt.Errorf("begin create hotel tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(queries.InsertHotelQuery,
+		sql.Named("id", id),
+		sql.Named("name", req.Name),
+		sql.Named("description", req.Description),
+		sql.Named("city", req.Location.City),
+		sql.Named("country", req.Location.Country),
+		sql.Named("image_url", req.ImageUrl),
+		sql.Named("price_per_night", req.PricePerNight),
+		sql.Named("rating", *req.Rating),
+		sql.Named("phone_number", req.PhoneNumber),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return models.Hotel{}, errors.ErrHotelNameTaken
+		}
+		return models.Hotel{}, fmt.Errorf("insert hotel: %w", err)
+	}
+
+	if err := setHotelFeatures(tx, id, req.Features); err != nil {
+		return models.Hotel{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Hotel{}, fmt.Errorf("commit create hotel tx: %w", err)
+	}
+
+	return hs.GetHotelById(id.String())
+}
+
+func (hs *HotelService) UpdateHotel(hotelId uuid.UUID, req models.HotelRequest) (models.Hotel, error) {
+	tx, err := hs.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return models.Hotel{}, fmt.Errorf("begin update hotel tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(queries.UpdateHotel,
+		sql.Named("name", req.Name),
+		sql.Named("description", req.Description),
+		sql.Named("city", req.Location.City),
+		sql.Named("country", req.Location.Country),
+		sql.Named("image_url", req.ImageUrl),
+		sql.Named("price_per_night", req.PricePerNight),
+		sql.Named("rating", *req.Rating),
+		sql.Named("phone_number", req.PhoneNumber),
+		sql.Named("id", hotelId),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return models.Hotel{}, errors.ErrHotelNameTaken
+		}
+		return models.Hotel{}, fmt.Errorf("update hotel: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return models.Hotel{}, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.Hotel{}, errors.ErrHotelNotFound
+	}
+
+	if err := setHotelFeatures(tx, hotelId, req.Features); err != nil {
+		return models.Hotel{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Hotel{}, fmt.Errorf("commit update hotel tx: %w", err)
+	}
+
+	return hs.GetHotelById(hotelId.String())
+}
+
+func (hs *HotelService) PatchHotel(hotelId uuid.UUID, patch models.HotelPatchRequest) (models.Hotel, error) {
+	hotel, err := hs.GetHotelById(hotelId.String())
+	if err != nil {
+		return models.Hotel{}, err
+	}
+
+	req, err := hotel.ToRequest()
+	if err != nil {
+		return models.Hotel{}, err
+	}
+
+	patch.Apply(&req)
+
+	return hs.UpdateHotel(hotelId, req)
+}
+
+func (hs *HotelService) DeleteHotel(hotelId uuid.UUID) error {
+	var count int
+	if err := hs.db.QueryRow(queries.CountHotelReservations, sql.Named("hotel_id", hotelId)).Scan(&count); err != nil {
+		return fmt.Errorf("count hotel reservations: %w", err)
+	}
+
+	if count > 0 {
+		return errors.ErrHotelHasReservations
+	}
+
+	// Room types and hotel features are removed by ON DELETE CASCADE
+	res, err := hs.db.Exec(queries.DeleteHotel, sql.Named("id", hotelId))
+	if err != nil {
+		return fmt.Errorf("delete hotel: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrHotelNotFound
+	}
+
+	return nil
+}
+
+// setHotelFeatures replaces the features of a hotel, creating the features that do not exist yet
+func setHotelFeatures(tx *sql.Tx, hotelId uuid.UUID, features []string) error {
+	if _, err := tx.Exec(queries.DeleteHotelFeatures, sql.Named("hotel_id", hotelId)); err != nil {
+		return fmt.Errorf("delete hotel features: %w", err)
+	}
+
+	seen := make(map[string]struct{})
+	for _, feature := range features {
+		feature = strings.TrimSpace(feature)
+		if feature == "" {
+			continue
+		}
+
+		if _, ok := seen[strings.ToLower(feature)]; ok {
+			continue
+		}
+		seen[strings.ToLower(feature)] = struct{}{}
+
+		if _, err := tx.Exec(queries.InsertFeature, sql.Named("name", feature)); err != nil {
+			return fmt.Errorf("insert feature: %w", err)
+		}
+
+		_, err := tx.Exec(queries.InsertHotelFeature,
+			sql.Named("hotel_id", hotelId),
+			sql.Named("name", feature),
+		)
+		if err != nil {
+			return fmt.Errorf("insert hotel feature: %w", err)
+		}
+	}
+
+	return nil
+}
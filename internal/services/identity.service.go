@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/oidc"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// IdentityService links users to the accounts they have at external identity providers
+type IdentityService struct {
+	db *sql.DB
+}
+
+func NewIdentityService(db *sql.DB) *IdentityService {
+	return &IdentityService{
+		db: db,
+	}
+}
+
+// Login returns the user linked to identity. An identity seen for the first time is linked to the user
+// with the same email, or to a new user when there is none. Both need the provider to have verified the email.
+func (s *IdentityService) Login(provider string, identity oidc.Identity) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow(queries.SelectUserByIdentity,
+		sql.Named("provider", provider),
+		sql.Named("subject", identity.Subject),
+	))
+	if err == nil {
+		return &user, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("get user by identity: %w", err)
+	}
+
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, errors.ErrUnverifiedIdentity
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin link identity tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	user, err = scanUser(tx.QueryRow(queries.SelectUserByEmail, sql.Named("email", identity.Email)))
+	switch {
+	case err == nil:
+		// Anyone can register an email they don't own, linking to an unverified account
+		// would hand the provider's user an account whose password someone else may know
+		if !user.IsEmailVerified() {
+			return nil, errors.ErrAccountLinkConflict
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = createIdentityUser(tx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("get user by email: %w", err)
+	}
+
+	_, err = tx.Exec(queries.InsertUserIdentity,
+		sql.Named("provider", provider),
+		sql.Named("subject", identity.Subject),
+		sql.Named("user_id", user.Id),
+		sql.Named("email", identity.Email),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("link identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit link identity tx: %w", err)
+	}
+
+	return &user, nil
+}
+
+// createIdentityUser creates a user with a random password nobody knows, they can set one with the forgot password flow
+func createIdentityUser(tx *sql.Tx, identity oidc.Identity) (models.User, error) {
+	password, err := utils.RandString(32)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("create identity user: %w", err)
+	}
+
+	now := time.Now()
+	user := models.User{
+		Id:              uuid.New(),
+		Name:            identityName(identity),
+		Email:           identity.Email,
+		PasswordHash:    passwordHash,
+		Role:            models.RoleUser,
+		CreatedAt:       now,
+		EmailVerifiedAt: &now,
+	}
+
+	_, err = tx.Exec(queries.InsertVerifiedUser,
+		sql.Named("id", user.Id),
+		sql.Named("name", user.Name),
+		sql.Named("email", user.Email),
+		sql.Named("password_hash", user.PasswordHash),
+		sql.Named("role", user.Role),
+		sql.Named("created_at", now),
+	)
+	if err != nil {
+		return models.User{}, fmt.Errorf("create identity user: %w", err)
+	}
+
+	return user, nil
+}
+
+// identityName fits the provider's name into users.name, which needs 3 to 50 characters
+func identityName(identity oidc.Identity) string {
+	name := strings.TrimSpace(identity.Name)
+	if len([]rune(name)) < 3 {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	if len([]rune(name)) < 3 {
+		name = "Guest"
+	}
+
+	if runes := []rune(name); len(runes) > 50 {
+		name = strings.TrimSpace(string(runes[:50]))
+	}
+
+	return name
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/oidc"
+	"github.com/google/uuid"
+)
+
+func testEmail() string {
+	return uuid.NewString() + "@example.com"
+}
+
+func registerTestUser(t *testing.T, us *UserService, email string, verified bool) uuid.UUID {
+	t.Helper()
+
+	id, err := us.RegisterUser(models.RegistrationRequest{Name: "Guest", Email: email, Password: "password123"})
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+
+	if verified {
+		if err := us.MarkEmailVerified(email); err != nil {
+			t.Fatalf("verify email: %v", err)
+		}
+	}
+
+	return id
+}
+
+func TestIdentityLoginLinksVerifiedAccount(t *testing.T) {
+	db := openTestDB(t)
+	userService := NewUserService(db)
+	identityService := NewIdentityService(db)
+
+	email := testEmail()
+	userId := registerTestUser(t, userService, email, true)
+
+	identity := oidc.Identity{Subject: uuid.NewString(), Email: email, EmailVerified: true, Name: "Guest"}
+
+	user, err := identityService.Login("fake", identity)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if user.Id != userId {
+		t.Fatalf("linked to user %s, want %s", user.Id, userId)
+	}
+
+	// The identity stays linked even if the provider's email changes later
+	identity.Email = testEmail()
+	user, err = identityService.Login("fake", identity)
+	if err != nil {
+		t.Fatalf("Login with the linked identity: %v", err)
+	}
+
+	if user.Id != userId {
+		t.Errorf("linked identity logged in as %s, want %s", user.Id, userId)
+	}
+}
+
+func TestIdentityLoginRefusesUnverifiedAccount(t *testing.T) {
+	db := openTestDB(t)
+	userService := NewUserService(db)
+	identityService := NewIdentityService(db)
+
+	email := testEmail()
+	registerTestUser(t, userService, email, false)
+
+	_, err := identityService.Login("fake", oidc.Identity{Subject: uuid.NewString(), Email: email, EmailVerified: true})
+	if !errors.Is(err, errors.ErrAccountLinkConflict) {
+		t.Fatalf("Login error = %v, want %v", err, errors.ErrAccountLinkConflict)
+	}
+}
+
+func TestIdentityLoginRefusesUnverifiedIdentity(t *testing.T) {
+	db := openTestDB(t)
+	identityService := NewIdentityService(db)
+
+	_, err := identityService.Login("fake", oidc.Identity{Subject: uuid.NewString(), Email: testEmail(), EmailVerified: false})
+	if !errors.Is(err, errors.ErrUnverifiedIdentity) {
+		t.Fatalf("Login error = %v, want %v", err, errors.ErrUnverifiedIdentity)
+	}
+}
+
+func TestIdentityLoginCreatesUser(t *testing.T) {
+	db := openTestDB(t)
+	userService := NewUserService(db)
+	identityService := NewIdentityService(db)
+
+	email := testEmail()
+	user, err := identityService.Login("fake", oidc.Identity{Subject: uuid.NewString(), Email: email, EmailVerified: true, Name: "Jo"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	stored, err := userService.GetUserByEmail(email)
+	if err != nil {
+		t.Fatalf("get created user: %v", err)
+	}
+
+	if stored.Id != user.Id || !stored.IsEmailVerified() || stored.Role != models.RoleUser {
+		t.Errorf("created user = %+v, want a verified user with id %s", stored, user.Id)
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+)
+
+// loginPolicy decides how long a subject has to wait after its consecutive failures
+type loginPolicy struct {
+	freeAttempts int           // failures allowed before any delay
+	maxBackoff   time.Duration // the exponential delay stops growing here
+	lockoutAfter int           // failures that lock the subject for lockout
+	lockout      time.Duration
+	resetAfter   time.Duration // failures older than this are forgotten
+}
+
+// An IP address gets more room than an account, many users can share one behind a NAT
+var loginPolicies = map[models.LoginScope]loginPolicy{
+	models.LoginScopeAccount: {
+		freeAttempts: 3,
+		maxBackoff:   15 * time.Minute,
+		lockoutAfter: 10,
+		lockout:      30 * time.Minute,
+		resetAfter:   time.Hour,
+	},
+	models.LoginScopeIP: {
+		freeAttempts: 20,
+		maxBackoff:   15 * time.Minute,
+		lockoutAfter: 100,
+		lockout:      time.Hour,
+		resetAfter:   time.Hour,
+	},
+}
+
+func (p loginPolicy) lockFor(failures int) time.Duration {
+	switch {
+	case failures >= p.lockoutAfter:
+		return p.lockout
+	case failures <= p.freeAttempts:
+		return 0
+	}
+
+	backoff := time.Second << min(failures-p.freeAttempts-1, 30)
+
+	return min(backoff, p.maxBackoff)
+}
+
+type LoginGuardService struct {
+	db *sql.DB
+}
+
+func NewLoginGuardService(db *sql.DB) *LoginGuardService {
+	return &LoginGuardService{
+		db: db,
+	}
+}
+
+// Check returns ErrLoginLocked and the time the lock ends when either the account or the IP address is locked
+func (s *LoginGuardService) Check(email, ip string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+
+	err := s.db.QueryRow(queries.SelectLoginLockout,
+		sql.Named("account_scope", models.LoginScopeAccount),
+		sql.Named("email", normalizeEmail(email)),
+		sql.Named("ip_scope", models.LoginScopeIP),
+		sql.Named("ip", ip),
+		sql.Named("now", time.Now()),
+	).Scan(&lockedUntil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("check login lockout: %w", err)
+	}
+
+	if lockedUntil.Valid {
+		return lockedUntil.Time, errors.ErrLoginLocked
+	}
+
+	return time.Time{}, nil
+}
+
+// RecordFailure counts a failed login for both the account and the IP address.
+// Emails without an account are counted too, so lockouts don't reveal which emails are registered.
+func (s *LoginGuardService) RecordFailure(email, ip string) error {
+	if err := s.recordFailure(models.LoginScopeAccount, normalizeEmail(email)); err != nil {
+		return err
+	}
+
+	return s.recordFailure(models.LoginScopeIP, ip)
+}
+
+// Unlock forgets the failed logins of the account, the IP address keeps its count
+// so one valid account can't be used to reset the limit of an attacker's address
+func (s *LoginGuardService) Unlock(email string) error {
+	_, err := s.db.Exec(queries.DeleteLoginAttempt,
+		sql.Named("scope", models.LoginScopeAccount),
+		sql.Named("subject", normalizeEmail(email)),
+	)
+	if err != nil {
+		return fmt.Errorf("unlock account: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LoginGuardService) recordFailure(scope models.LoginScope, subject string) error {
+	policy := loginPolicies[scope]
+	now := time.Now()
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin login attempt tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var attempt models.LoginAttempt
+	err = tx.QueryRow(queries.SelectLoginAttemptForUpdate,
+		sql.Named("scope", scope),
+		sql.Named("subject", subject),
+	).Scan(&attempt.Scope, &attempt.Subject, &attempt.Failures, &attempt.LastFailedAt, &attempt.LockedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("get login attempt: %w", err)
+	}
+
+	if now.Sub(attempt.LastFailedAt) > policy.resetAfter {
+		attempt.Failures = 0
+	}
+
+	attempt.Failures++
+
+	var lockedUntil *time.Time
+	if lockFor := policy.lockFor(attempt.Failures); lockFor > 0 {
+		until := now.Add(lockFor)
+		lockedUntil = &until
+	}
+
+	_, err = tx.Exec(queries.UpsertLoginAttempt,
+		sql.Named("scope", scope),
+		sql.Named("subject", subject),
+		sql.Named("failures", attempt.Failures),
+		sql.Named("last_failed_at", now),
+		sql.Named("locked_until", lockedUntil),
+	)
+	if err != nil {
+		return fmt.Errorf("save login attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit login attempt tx: %w", err)
+	}
+
+	return nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
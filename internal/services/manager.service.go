@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// ManagerService scopes hotel and reservation operations to the hotels a manager is assigned to
+type ManagerService struct {
+	db                 *sql.DB
+	hotelService       *HotelService
+	reservationService *ReservationService
+}
+
+func NewManagerService(db *sql.DB, hotelService *HotelService, reservationService *ReservationService) *ManagerService {
+	return &ManagerService{
+		db:                 db,
+		hotelService:       hotelService,
+		reservationService: reservationService,
+	}
+}
+
+func (ms *ManagerService) GetHotels(uid uuid.UUID) ([]models.Hotel, error) {
+	rows, err := ms.db.Query(queries.SelectHotelsByStaff, sql.Named("user_id", uid))
+	if err != nil {
+		return nil, fmt.Errorf("get managed hotels: %w", err)
+	}
+
+	return scanHotels(rows)
+}
+
+// UpdateHotel replaces the fields a manager owns, the rating and other admin fields keep their values
+func (ms *ManagerService) UpdateHotel(uid, hotelId uuid.UUID, req models.ManagerHotelRequest) (models.Hotel, error) {
+	if err := ms.authorize(uid, hotelId); err != nil {
+		return models.Hotel{}, err
+	}
+
+	return ms.hotelService.PatchHotel(hotelId, req.ToPatch())
+}
+
+func (ms *ManagerService) PatchHotel(uid, hotelId uuid.UUID, patch models.ManagerHotelPatchRequest) (models.Hotel, error) {
+	if err := ms.authorize(uid, hotelId); err != nil {
+		return models.Hotel{}, err
+	}
+
+	return ms.hotelService.PatchHotel(hotelId, patch.ToPatch())
+}
+
+func (ms *ManagerService) GetReservations(uid, hotelId uuid.UUID) ([]models.Reservation, error) {
+	if err := ms.authorize(uid, hotelId); err != nil {
+		return nil, err
+	}
+
+	return ms.reservationService.GetAllReservations(&hotelId)
+}
+
+// AssignStaff makes userId a manager of hotelId, plain users are promoted to hotel managers
+func (ms *ManagerService) AssignStaff(hotelId, userId uuid.UUID) error {
+	tx, err := ms.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin assign staff tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(queries.CountHotelsById, sql.Named("id", hotelId)).Scan(&count); err != nil {
+		return fmt.Errorf("check hotel exists: %w", err)
+	}
+
+	if count == 0 {
+		return errors.ErrHotelNotFound
+	}
+
+	var role models.Role
+	if err := tx.QueryRow(queries.SelectUserRoleById, sql.Named("id", userId)).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.ErrUserNotFound
+		}
+		return fmt.Errorf("get user role: %w", err)
+	}
+
+	switch role {
+	case models.RoleHotelManager:
+	case models.RoleUser:
+		_, err = tx.Exec(queries.PromoteUserToHotelManager,
+			sql.Named("role", models.RoleHotelManager),
+			sql.Named("id", userId),
+			sql.Named("from_role", models.RoleUser),
+		)
+		if err != nil {
+			return fmt.Errorf("promote user: %w", err)
+		}
+	default:
+		return errors.ErrStaffRoleConflict
+	}
+
+	_, err = tx.Exec(queries.InsertHotelStaff,
+		sql.Named("hotel_id", hotelId),
+		sql.Named("user_id", userId),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("insert hotel staff: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit assign staff tx: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveStaff unassigns userId from hotelId, a manager left without hotels is demoted to a plain user
+func (ms *ManagerService) RemoveStaff(hotelId, userId uuid.UUID) error {
+	tx, err := ms.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin remove staff tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(queries.DeleteHotelStaff,
+		sql.Named("hotel_id", hotelId),
+		sql.Named("user_id", userId),
+	)
+	if err != nil {
+		return fmt.Errorf("delete hotel staff: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrNotHotelStaff
+	}
+
+	_, err = tx.Exec(queries.DemoteHotelManager,
+		sql.Named("role", models.RoleUser),
+		sql.Named("id", userId),
+		sql.Named("from_role", models.RoleHotelManager),
+	)
+	if err != nil {
+		return fmt.Errorf("demote hotel manager: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit remove staff tx: %w", err)
+	}
+
+	return nil
+}
+
+func (ms *ManagerService) authorize(uid, hotelId uuid.UUID) error {
+	var count int
+	err := ms.db.QueryRow(queries.CountHotelStaff,
+		sql.Named("hotel_id", hotelId),
+		sql.Named("user_id", uid),
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("check hotel staff: %w", err)
+	}
+
+	if count == 0 {
+		return errors.ErrNotHotelStaff
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/totp"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	mfaIssuer         = "Hotel Booking"
+	recoveryCodeCount = 10
+)
+
+// MFAService stores the TOTP secrets encrypted with cipher, they have to be read back to check codes so they can't be hashed
+type MFAService struct {
+	db     *sql.DB
+	cipher *utils.Cipher
+}
+
+func NewMFAService(db *sql.DB, cipher *utils.Cipher) *MFAService {
+	return &MFAService{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+// Enroll creates a new TOTP secret for the user and returns it with its otpauth:// URI.
+// The secret is not enforced until it is confirmed with a code.
+func (s *MFAService) Enroll(uid uuid.UUID, email string) (string, string, error) {
+	mfa, err := s.getUserMFA(uid)
+	if err != nil && !errors.Is(err, errors.ErrMFANotEnrolled) {
+		return "", "", err
+	}
+
+	if err == nil && mfa.IsConfirmed() {
+		return "", "", errors.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	encryptedSecret, err := s.cipher.Encrypt(secret, uid.String())
+	if err != nil {
+		return "", "", fmt.Errorf("encrypt mfa secret: %w", err)
+	}
+
+	_, err = s.db.Exec(queries.UpsertUserMFA,
+		sql.Named("user_id", uid),
+		sql.Named("secret", encryptedSecret),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("save mfa secret: %w", err)
+	}
+
+	return secret, totp.URI(mfaIssuer, email, secret), nil
+}
+
+// Confirm enables two-factor authentication once the user proves their app generates valid codes.
+// It returns the recovery codes, they are only shown this once.
+func (s *MFAService) Confirm(uid uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.getUserMFA(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.IsConfirmed() {
+		return nil, errors.ErrMFAAlreadyEnabled
+	}
+
+	usedStep, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, errors.ErrInvalidMFACode
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin confirm mfa tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(queries.ConfirmUserMFA,
+		sql.Named("confirmed_at", time.Now()),
+		sql.Named("step", usedStep),
+		sql.Named("user_id", uid),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("confirm mfa: %w", err)
+	}
+
+	codes, err := replaceRecoveryCodes(tx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit confirm mfa tx: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (s *MFAService) IsEnabled(uid uuid.UUID) (bool, error) {
+	mfa, err := s.getUserMFA(uid)
+	if err != nil {
+		if errors.Is(err, errors.ErrMFANotEnrolled) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return mfa.IsConfirmed(), nil
+}
+
+// Verify accepts a TOTP code that hasn't been used yet or an unused recovery code
+func (s *MFAService) Verify(uid uuid.UUID, code string) error {
+	mfa, err := s.getUserMFA(uid)
+	if err != nil {
+		return err
+	}
+
+	if !mfa.IsConfirmed() {
+		return errors.ErrMFANotEnrolled
+	}
+
+	if usedStep, ok := totp.Validate(mfa.Secret, code, time.Now()); ok {
+		return s.useStep(uid, usedStep)
+	}
+
+	return s.useRecoveryCode(uid, code)
+}
+
+// Disable removes the secret and the recovery codes, it requires a valid code like a login does
+func (s *MFAService) Disable(uid uuid.UUID, code string) error {
+	if err := s.Verify(uid, code); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin disable mfa tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(queries.DeleteRecoveryCodes, sql.Named("user_id", uid)); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.Exec(queries.DeleteUserMFA, sql.Named("user_id", uid)); err != nil {
+		return fmt.Errorf("delete mfa: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit disable mfa tx: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MFAService) getUserMFA(uid uuid.UUID) (models.UserMFA, error) {
+	var mfa models.UserMFA
+
+	err := s.db.QueryRow(queries.SelectUserMFA, sql.Named("user_id", uid)).Scan(
+		&mfa.UserId,
+		&mfa.Secret,
+		&mfa.LastUsedStep,
+		&mfa.ConfirmedAt,
+		&mfa.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserMFA{}, errors.ErrMFANotEnrolled
+		}
+		return models.UserMFA{}, fmt.Errorf("get mfa: %w", err)
+	}
+
+	mfa.Secret, err = s.cipher.Decrypt(mfa.Secret, uid.String())
+	if err != nil {
+		return models.UserMFA{}, fmt.Errorf("decrypt mfa secret: %w", err)
+	}
+
+	return mfa, nil
+}
+
+func (s *MFAService) useStep(uid uuid.UUID, step int64) error {
+	res, err := s.db.Exec(queries.UseMFAStep,
+		sql.Named("step", step),
+		sql.Named("user_id", uid),
+	)
+	if err != nil {
+		return fmt.Errorf("use mfa step: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	// The code was already used
+	if rowsAffected == 0 {
+		return errors.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *MFAService) useRecoveryCode(uid uuid.UUID, code string) error {
+	res, err := s.db.Exec(queries.UseRecoveryCode,
+		sql.Named("used_at", time.Now()),
+		sql.Named("user_id", uid),
+		sql.Named("code_hash", utils.Hash(normalizeRecoveryCode(code))),
+	)
+	if err != nil {
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, uid uuid.UUID) ([]string, error) {
+	if _, err := tx.Exec(queries.DeleteRecoveryCodes, sql.Named("user_id", uid)); err != nil {
+		return nil, fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(queries.InsertRecoveryCode,
+			sql.Named("id", uuid.New()),
+			sql.Named("user_id", uid),
+			sql.Named("code_hash", utils.Hash(code)),
+			sql.Named("created_at", now),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("save recovery code: %w", err)
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode returns 10 random base32 characters grouped as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("generate recovery code: %w", err)
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))[:10]
+
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode accepts codes typed in upper case or with surrounding spaces
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
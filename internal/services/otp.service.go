@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
 	"time"
@@ -12,6 +14,13 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	otpLength         = 6
+	otpExpiresIn      = 10 * time.Minute
+	otpMaxAttempts    = 5
+	otpResendCooldown = time.Minute
+)
+
 type OTPService struct {
 	db *sql.DB
 }
@@ -22,63 +31,182 @@ func NewOTPService(db *sql.DB) *OTPService {
 	}
 }
 
-func (s *OTPService) GenerateOTP(email string) (string, error) {
-	// Generate a 6-digit OTP
-	otp, err := utils.GenerateNumericOTP(6)
+// GenerateOTP creates a code for email, a previous code of the same email and purpose is replaced
+// once otpResendCooldown has passed since it was sent
+func (s *OTPService) GenerateOTP(email string, purpose models.OTPPurpose) (string, error) {
+	previous, err := s.getOTPToken(email, purpose)
+	if err != nil && !errors.Is(err, errors.ErrInvalidOTP) {
+		return "", err
+	}
+
+	if err == nil && time.Since(previous.CreatedAt) < otpResendCooldown {
+		return "", errors.ErrOTPCooldown
+	}
+
+	otp, err := utils.GenerateNumericOTP(otpLength)
 	if err != nil {
 		return "", fmt.Errorf("generate otp: %w", err)
 	}
 
-	// Create OTP token record
-	id := uuid.New()
+	if err := s.replaceOTPToken(email, otp, purpose); err != nil {
+		return "", err
+	}
+
+	return otp, nil
+}
+
+// GenerateMagicLink stores a random nonce for a login link the same way a code is stored,
+// the same cooldown applies between two links
+func (s *OTPService) GenerateMagicLink(email string) (string, error) {
+	previous, err := s.getOTPToken(email, models.OTPPurposeMagicLink)
+	if err != nil && !errors.Is(err, errors.ErrInvalidOTP) {
+		return "", err
+	}
+
+	if err == nil && time.Since(previous.CreatedAt) < otpResendCooldown {
+		return "", errors.ErrOTPCooldown
+	}
+
+	nonce, err := utils.RandString(32)
+	if err != nil {
+		return "", fmt.Errorf("generate magic link nonce: %w", err)
+	}
+
+	if err := s.replaceOTPToken(email, nonce, models.OTPPurposeMagicLink); err != nil {
+		return "", err
+	}
+
+	return nonce, nil
+}
+
+// ConsumeMagicLink accepts the nonce of the latest link sent to email once.
+// The nonce can't be guessed, so unlike codes the attempts aren't counted.
+func (s *OTPService) ConsumeMagicLink(email, nonce string) error {
+	res, err := s.db.Exec(queries.ConsumeOTPToken,
+		sql.Named("email", email),
+		sql.Named("purpose", models.OTPPurposeMagicLink),
+		sql.Named("token_hash", utils.Hash(nonce)),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("consume magic link: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrMagicLinkUsed
+	}
+
+	return nil
+}
+
+// replaceOTPToken stores the hash of secret as the only token of email and purpose
+func (s *OTPService) replaceOTPToken(email, secret string, purpose models.OTPPurpose) error {
 	now := time.Now()
-	expiresAt := now.Add(10 * time.Minute) // OTP expires in 10 minutes
 
-	_, err = s.db.Exec(queries.InsertOTPToken,
-		sql.Named("id", id),
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("begin otp tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(queries.DeleteOTPTokenByEmail,
+		sql.Named("email", email),
+		sql.Named("purpose", purpose),
+	)
+	if err != nil {
+		return fmt.Errorf("delete previous otp: %w", err)
+	}
+
+	_, err = tx.Exec(queries.InsertOTPToken,
+		sql.Named("id", uuid.New()),
 		sql.Named("email", email),
-		sql.Named("token_hash", utils.Hash(otp)),
-		sql.Named("expires_at", expiresAt),
+		sql.Named("token_hash", utils.Hash(secret)),
+		sql.Named("expires_at", now.Add(otpExpiresIn)),
 		sql.Named("created_at", now),
+		sql.Named("purpose", purpose),
 	)
 	if err != nil {
-		return "", fmt.Errorf("save otp token: %w", err)
+		return fmt.Errorf("save otp token: %w", err)
 	}
 
-	return otp, nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit otp tx: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyOTP checks otp against the code sent to email. Every guess counts as an attempt,
+// after otpMaxAttempts the code can't be used anymore and a new one has to be requested.
+func (s *OTPService) VerifyOTP(email, otp string, purpose models.OTPPurpose) (bool, error) {
+	token, err := s.getOTPToken(email, purpose)
+	if err != nil {
+		return false, err
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return false, errors.ErrOTPExpired
+	}
+
+	res, err := s.db.Exec(queries.CountOTPAttempt,
+		sql.Named("id", token.Id),
+		sql.Named("max_attempts", otpMaxAttempts),
+	)
+	if err != nil {
+		return false, fmt.Errorf("count otp attempt: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, errors.ErrOTPAttemptsExceeded
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.Hash(otp)), []byte(token.TokenHash)) != 1 {
+		if token.Attempts+1 >= otpMaxAttempts {
+			return false, errors.ErrOTPAttemptsExceeded
+		}
+		return false, errors.ErrInvalidOTP
+	}
+
+	// Delete the OTP after successful verification
+	_, err = s.db.Exec(queries.DeleteOTPToken, sql.Named("id", token.Id))
+	if err != nil {
+		return false, fmt.Errorf("delete otp: %w", err)
+	}
+
+	return true, nil
 }
 
-func (s *OTPService) VerifyOTP(email, otp string) (bool, error) {
+func (s *OTPService) getOTPToken(email string, purpose models.OTPPurpose) (models.OTPToken, error) {
 	var token models.OTPToken
 
-	// Then verify the OTP
-	err := s.db.QueryRow(queries.SelectOTPToken,
+	err := s.db.QueryRow(queries.SelectOTPTokenByEmail,
 		sql.Named("email", email),
-		sql.Named("token_hash", utils.Hash(otp)),
+		sql.Named("purpose", purpose),
 	).Scan(
 		&token.Id,
 		&token.Email,
 		&token.TokenHash,
 		&token.ExpiresAt,
 		&token.CreatedAt,
+		&token.Attempts,
+		&token.Purpose,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, errors.New("invalid otp")
+			return models.OTPToken{}, errors.ErrInvalidOTP
 		}
-		return false, fmt.Errorf("verify otp: %w", err)
-	}
-
-	// Check if OTP is expired
-	if time.Now().After(token.ExpiresAt) {
-		return false, errors.New("otp expired")
-	}
-
-	// Delete the OTP after successful verification
-	_, err = s.db.Exec(queries.DeleteOTPToken, sql.Named("token_hash", token.TokenHash))
-	if err != nil {
-		return false, fmt.Errorf("delete otp: %w", err)
+		return models.OTPToken{}, fmt.Errorf("get otp: %w", err)
 	}
 
-	return true, nil
+	return token, nil
 }
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/google/uuid"
+)
+
+type ReservationService struct {
+	db *sql.DB
+}
+
+func NewReservationService(db *sql.DB) *ReservationService {
+	return &ReservationService{
+		db: db,
+	}
+}
+
+func (rs *ReservationService) CreateReservation(uid uuid.UUID, req models.ReservationRequest) (models.Reservation, error) {
+	checkIn, checkOut, err := req.StayDates()
+	if err != nil {
+		return models.Reservation{}, errors.ErrInvalidStayDates
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	nights := models.Nights(checkIn, checkOut)
+	if nights <= 0 || checkIn.Before(today) {
+		return models.Reservation{}, errors.ErrInvalidStayDates
+	}
+
+	tx, err := rs.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return models.Reservation{}, fmt.Errorf("begin reservation tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var capacity, unitCount int
+	var basePrice float64
+	err = tx.QueryRow(queries.SelectRoomTypeForReservation,
+		sql.Named("id", req.RoomTypeId),
+		sql.Named("hotel_id", req.HotelId),
+	).Scan(&capacity, &basePrice, &unitCount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Reservation{}, errors.ErrRoomTypeNotFound
+		}
+		return models.Reservation{}, fmt.Errorf("get room type for reservation: %w", err)
+	}
+
+	if req.GuestCount > capacity {
+		return models.Reservation{}, errors.ErrGuestLimitExceeded
+	}
+
+	occupancy, err := peakOccupancy(tx, req.RoomTypeId, checkIn, checkOut)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	if occupancy >= unitCount {
+		return models.Reservation{}, errors.ErrNoAvailability
+	}
+
+	reservation := models.Reservation{
+		Id:           uuid.New(),
+		UserId:       uid,
+		HotelId:      uuid.MustParse(req.HotelId),
+		RoomTypeId:   uuid.MustParse(req.RoomTypeId),
+		CheckInDate:  checkIn,
+		CheckOutDate: checkOut,
+		GuestCount:   req.GuestCount,
+		TotalPrice:   basePrice * float64(nights),
+		Status:       models.ReservationPending,
+		CreatedAt:    time.Now(),
+	}
+
+	_, err = tx.Exec(queries.InsertReservation,
+		sql.Named("id", reservation.Id),
+		sql.Named("user_id", reservation.UserId),
+		sql.Named("hotel_id", reservation.HotelId),
+		sql.Named("room_type_id", reservation.RoomTypeId),
+		sql.Named("check_in_date", reservation.CheckInDate),
+		sql.Named("check_out_date", reservation.CheckOutDate),
+		sql.Named("guest_count", reservation.GuestCount),
+		sql.Named("total_price", reservation.TotalPrice),
+		sql.Named("status", reservation.Status),
+		sql.Named("created_at", reservation.CreatedAt),
+	)
+	if err != nil {
+		return models.Reservation{}, fmt.Errorf("insert reservation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Reservation{}, fmt.Errorf("commit reservation tx: %w", err)
+	}
+
+	return reservation, nil
+}
+
+func (rs *ReservationService) GetReservations(uid uuid.UUID) ([]models.Reservation, error) {
+	rows, err := rs.db.Query(queries.SelectReservationsByUserId, sql.Named("user_id", uid))
+	if err != nil {
+		return nil, fmt.Errorf("get reservations: %w", err)
+	}
+
+	defer rows.Close()
+
+	reservations := []models.Reservation{}
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reservation: %w", err)
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get reservations: %w", err)
+	}
+
+	return reservations, nil
+}
+
+// GetAllReservations returns the reservations of every user, only of hotelId when it is not nil
+func (rs *ReservationService) GetAllReservations(hotelId *uuid.UUID) ([]models.Reservation, error) {
+	var hotelFilter any
+	if hotelId != nil {
+		hotelFilter = *hotelId
+	}
+
+	rows, err := rs.db.Query(queries.SelectAllReservations, sql.Named("hotel_id", hotelFilter))
+	if err != nil {
+		return nil, fmt.Errorf("get all reservations: %w", err)
+	}
+
+	defer rows.Close()
+
+	reservations := []models.Reservation{}
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reservation: %w", err)
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get all reservations: %w", err)
+	}
+
+	return reservations, nil
+}
+
+func (rs *ReservationService) GetReservationById(uid, reservationId uuid.UUID) (models.Reservation, error) {
+	reservation, err := scanReservation(rs.db.QueryRow(queries.SelectReservationById,
+		sql.Named("id", reservationId),
+		sql.Named("user_id", uid),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Reservation{}, errors.ErrReservationNotFound
+		}
+		return models.Reservation{}, fmt.Errorf("get reservation by id: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// CancelReservation cancels a reservation of uid, the update is conditional so only one of concurrent cancellations succeeds
+func (rs *ReservationService) CancelReservation(uid, reservationId uuid.UUID) (models.Reservation, error) {
+	res, err := rs.db.Exec(queries.CancelReservation,
+		sql.Named("status", models.ReservationCancelled),
+		sql.Named("id", reservationId),
+		sql.Named("user_id", uid),
+	)
+	if err != nil {
+		return models.Reservation{}, fmt.Errorf("cancel reservation: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return models.Reservation{}, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	reservation, err := rs.GetReservationById(uid, reservationId)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+
+	// The reservation exists but another request cancelled it first
+	if rowsAffected == 0 {
+		return models.Reservation{}, errors.ErrAlreadyCancelled
+	}
+
+	return reservation, nil
+}
+
+// CancelAnyReservation cancels a reservation on behalf of its guest
+func (rs *ReservationService) CancelAnyReservation(reservationId uuid.UUID) (models.Reservation, error) {
+	reservation, err := scanReservation(rs.db.QueryRow(queries.SelectAnyReservationById, sql.Named("id", reservationId)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Reservation{}, errors.ErrReservationNotFound
+		}
+		return models.Reservation{}, fmt.Errorf("get reservation by id: %w", err)
+	}
+
+	return rs.CancelReservation(reservation.UserId, reservationId)
+}
+
+// peakOccupancy returns the highest number of units of a room type booked on any night between checkIn and checkOut.
+// It must run inside the transaction that holds the room type lock.
+func peakOccupancy(tx *sql.Tx, roomTypeId string, checkIn, checkOut time.Time) (int, error) {
+	rows, err := tx.Query(queries.SelectOverlappingReservations,
+		sql.Named("room_type_id", roomTypeId),
+		sql.Named("check_in_date", checkIn),
+		sql.Named("check_out_date", checkOut),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("get overlapping reservations: %w", err)
+	}
+
+	defer rows.Close()
+
+	var stays [][2]time.Time
+	for rows.Next() {
+		var stay [2]time.Time
+		if err := rows.Scan(&stay[0], &stay[1]); err != nil {
+			return 0, fmt.Errorf("scan overlapping reservation: %w", err)
+		}
+		stays = append(stays, stay)
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("get overlapping reservations: %w", err)
+	}
+
+	peak := 0
+	for night := checkIn; night.Before(checkOut); night = night.AddDate(0, 0, 1) {
+		booked := 0
+		for _, stay := range stays {
+			if !night.Before(stay[0]) && night.Before(stay[1]) {
+				booked++
+			}
+		}
+		peak = max(peak, booked)
+	}
+
+	return peak, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReservation(row rowScanner) (models.Reservation, error) {
+	var reservation models.Reservation
+
+	err := row.Scan(
+		&reservation.Id,
+		&reservation.UserId,
+		&reservation.HotelId,
+		&reservation.RoomTypeId,
+		&reservation.CheckInDate,
+		&reservation.CheckOutDate,
+		&reservation.GuestCount,
+		&reservation.TotalPrice,
+		&reservation.Status,
+		&reservation.CreatedAt,
+	)
+
+	return reservation, err
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/google/uuid"
+)
+
+func createTestHotel(t *testing.T, db *sql.DB) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	_, err := db.Exec(queries.InsertHotelQuery,
+		sql.Named("id", id),
+		sql.Named("name", "Test Hotel "+id.String()),
+		sql.Named("description", "A hotel created by the reservation tests"),
+		sql.Named("city", "Istanbul"),
+		sql.Named("country", "Turkey"),
+		sql.Named("image_url", "https://example.com/hotel.jpg"),
+		sql.Named("price_per_night", 100),
+		sql.Named("rating", 4.5),
+		sql.Named("phone_number", "+900000000000"),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		t.Fatalf("create hotel: %v", err)
+	}
+
+	return id
+}
+
+func createTestUsers(t *testing.T, db *sql.DB, count int) []uuid.UUID {
+	t.Helper()
+
+	userService := NewUserService(db)
+
+	users := make([]uuid.UUID, count)
+	for i := range users {
+		id, err := userService.RegisterUser(models.RegistrationRequest{
+			Name:     "Guest",
+			Email:    uuid.NewString() + "@example.com",
+			Password: "password123",
+		})
+		if err != nil {
+			t.Fatalf("register user: %v", err)
+		}
+
+		users[i] = id
+	}
+
+	return users
+}
+
+// Concurrent bookings of the same dates can't take more units than the room type has
+func TestCreateReservationConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	roomTypeService := NewRoomTypeService(db)
+	reservationService := NewReservationService(db)
+
+	const unitCount = 3
+	const bookings = 10
+
+	hotelId := createTestHotel(t, db)
+	roomType, err := roomTypeService.CreateRoomType(hotelId, models.RoomTypeRequest{
+		Name:             "Double",
+		Description:      "Two guests",
+		Capacity:         2,
+		BedConfiguration: "1 double bed",
+		BasePrice:        100,
+		UnitCount:        unitCount,
+	})
+	if err != nil {
+		t.Fatalf("create room type: %v", err)
+	}
+
+	checkIn := time.Now().UTC().AddDate(0, 0, 7)
+	req := models.ReservationRequest{
+		HotelId:      hotelId.String(),
+		RoomTypeId:   roomType.Id.String(),
+		CheckInDate:  checkIn.Format("2006-01-02"),
+		CheckOutDate: checkIn.AddDate(0, 0, 2).Format("2006-01-02"),
+		GuestCount:   2,
+	}
+
+	users := createTestUsers(t, db, bookings)
+	errs := make([]error, bookings)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range bookings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = reservationService.CreateReservation(users[i], req)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, errors.ErrNoAvailability):
+			t.Errorf("booking %d: error = %v, want %v", i, err, errors.ErrNoAvailability)
+		}
+	}
+
+	if succeeded != unitCount {
+		t.Fatalf("%d bookings succeeded, want %d", succeeded, unitCount)
+	}
+
+	// The stay after the booked one doesn't overlap it and is still available
+	req.CheckInDate, req.CheckOutDate = req.CheckOutDate, checkIn.AddDate(0, 0, 3).Format("2006-01-02")
+	if _, err := reservationService.CreateReservation(users[0], req); err != nil {
+		t.Fatalf("book the following night: %v", err)
+	}
+}
+
+// Only one of concurrent cancellations of the same reservation succeeds
+func TestCancelReservationConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	roomTypeService := NewRoomTypeService(db)
+	reservationService := NewReservationService(db)
+
+	const cancellations = 5
+
+	hotelId := createTestHotel(t, db)
+	roomType, err := roomTypeService.CreateRoomType(hotelId, models.RoomTypeRequest{
+		Name:             "Single",
+		Description:      "One guest",
+		Capacity:         1,
+		BedConfiguration: "1 single bed",
+		BasePrice:        100,
+		UnitCount:        1,
+	})
+	if err != nil {
+		t.Fatalf("create room type: %v", err)
+	}
+
+	checkIn := time.Now().UTC().AddDate(0, 0, 7)
+	uid := createTestUsers(t, db, 1)[0]
+	reservation, err := reservationService.CreateReservation(uid, models.ReservationRequest{
+		HotelId:      hotelId.String(),
+		RoomTypeId:   roomType.Id.String(),
+		CheckInDate:  checkIn.Format("2006-01-02"),
+		CheckOutDate: checkIn.AddDate(0, 0, 1).Format("2006-01-02"),
+		GuestCount:   1,
+	})
+	if err != nil {
+		t.Fatalf("create reservation: %v", err)
+	}
+
+	errs := make([]error, cancellations)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range cancellations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = reservationService.CancelReservation(uid, reservation.Id)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, errors.ErrAlreadyCancelled):
+			t.Errorf("cancellation %d: error = %v, want %v", i, err, errors.ErrAlreadyCancelled)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Fatalf("%d cancellations succeeded, want 1", succeeded)
+	}
+
+	if _, err := reservationService.CancelReservation(createTestUsers(t, db, 1)[0], reservation.Id); !errors.Is(err, errors.ErrReservationNotFound) {
+		t.Fatalf("cancel another user's reservation: error = %v, want %v", err, errors.ErrReservationNotFound)
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/db"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/google/uuid"
+)
+
+type RoomTypeService struct {
+	db *sql.DB
+}
+
+func NewRoomTypeService(db *sql.DB) *RoomTypeService {
+	return &RoomTypeService{
+		db: db,
+	}
+}
+
+func (rts *RoomTypeService) GetRoomTypes(hotelId uuid.UUID) ([]models.RoomType, error) {
+	rows, err := rts.db.Query(queries.SelectRoomTypesByHotelId, sql.Named("hotel_id", hotelId))
+	if err != nil {
+		return nil, fmt.Errorf("get room types: %w", err)
+	}
+
+	defer rows.Close()
+
+	roomTypes := []models.RoomType{}
+	for rows.Next() {
+		roomType, err := scanRoomType(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan room type: %w", err)
+		}
+
+		roomTypes = append(roomTypes, roomType)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get room types: %w", err)
+	}
+
+	return roomTypes, nil
+}
+
+func (rts *RoomTypeService) GetRoomTypeById(hotelId, roomTypeId uuid.UUID) (models.RoomType, error) {
+	roomType, err := scanRoomType(rts.db.QueryRow(queries.SelectRoomTypeById,
+		sql.Named("id", roomTypeId),
+		sql.Named("hotel_id", hotelId),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RoomType{}, errors.ErrRoomTypeNotFound
+		}
+		return models.RoomType{}, fmt.Errorf("get room type by id: %w", err)
+	}
+
+	return roomType, nil
+}
+
+func (rts *RoomTypeService) CreateRoomType(hotelId uuid.UUID, req models.RoomTypeRequest) (models.RoomType, error) {
+	var count int
+	if err := rts.db.QueryRow(queries.CountHotelsById, sql.Named("id", hotelId)).Scan(&count); err != nil {
+		return models.RoomType{}, fmt.Errorf("check hotel exists: %w", err)
+	}
+
+	if count == 0 {
+		return models.RoomType{}, errors.ErrHotelNotFound
+	}
+
+	id := uuid.New()
+
+	_, err := rts.db.Exec(queries.InsertRoomType,
+		sql.Named("id", id),
+		sql.Named("hotel_id", hotelId),
+		sql.Named("name", req.Name),
+		sql.Named("description", req.Description),
+		sql.Named("capacity", req.Capacity),
+		sql.Named("bed_configuration", req.BedConfiguration),
+		sql.Named("base_price", req.BasePrice),
+		sql.Named("unit_count", req.UnitCount),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return models.RoomType{}, errors.ErrRoomTypeNameTaken
+		}
+		return models.RoomType{}, fmt.Errorf("insert room type: %w", err)
+	}
+
+	return rts.GetRoomTypeById(hotelId, id)
+}
+
+func (rts *RoomTypeService) UpdateRoomType(hotelId, roomTypeId uuid.UUID, req models.RoomTypeRequest) (models.RoomType, error) {
+	res, err := rts.db.Exec(queries.UpdateRoomType,
+		sql.Named("name", req.Name),
+		sql.Named("description", req.Description),
+		sql.Named("capacity", req.Capacity),
+		sql.Named("bed_configuration", req.BedConfiguration),
+		sql.Named("base_price", req.BasePrice),
+		sql.Named("unit_count", req.UnitCount),
+		sql.Named("id", roomTypeId),
+		sql.Named("hotel_id", hotelId),
+	)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return models.RoomType{}, errors.ErrRoomTypeNameTaken
+		}
+		return models.RoomType{}, fmt.Errorf("update room type: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return models.RoomType{}, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return models.RoomType{}, errors.ErrRoomTypeNotFound
+	}
+
+	return rts.GetRoomTypeById(hotelId, roomTypeId)
+}
+
+func (rts *RoomTypeService) DeleteRoomType(hotelId, roomTypeId uuid.UUID) error {
+	var count int
+	if err := rts.db.QueryRow(queries.CountRoomTypeReservations, sql.Named("room_type_id", roomTypeId)).Scan(&count); err != nil {
+		return fmt.Errorf("count room type reservations: %w", err)
+	}
+
+	// Reservations keep a reference to the room type they were made for
+	if count > 0 {
+		return errors.ErrRoomTypeInUse
+	}
+
+	res, err := rts.db.Exec(queries.DeleteRoomType,
+		sql.Named("id", roomTypeId),
+		sql.Named("hotel_id", hotelId),
+	)
+	if err != nil {
+		return fmt.Errorf("delete room type: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrRoomTypeNotFound
+	}
+
+	return nil
+}
+
+func scanRoomType(row rowScanner) (models.RoomType, error) {
+	var roomType models.RoomType
+
+	err := row.Scan(
+		&roomType.Id,
+		&roomType.HotelId,
+		&roomType.Name,
+		&roomType.Description,
+		&roomType.Capacity,
+		&roomType.BedConfiguration,
+		&roomType.BasePrice,
+		&roomType.UnitCount,
+		&roomType.CreatedAt,
+	)
+
+	return roomType, err
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations"
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+// openTestDB connects to the SQL Server named by TEST_DATABASE_DSN and migrates it,
+// tests that need a database are skipped when it isn't set
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := sql.Open("sqlserver", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("connect to database: %v", err)
+	}
+
+	if err := migrations.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	return db
+}
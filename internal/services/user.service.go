@@ -14,6 +14,9 @@ import (
 	"github.com/jackc/pgerrcode"
 )
 
+// dummyPasswordHash is a bcrypt hash with the default cost that no password is checked against successfully
+const dummyPasswordHash = "$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z3wGcC4GBdsW5WNeWqp0C1KW"
+
 type UserService struct {
 	db *sql.DB
 }
@@ -53,10 +56,11 @@ func (us *UserService) RegisterUser(registrationReq models.RegistrationRequest)
 }
 
 func (us *UserService) AuthenticateUser(loginReq models.LoginRequest) (*models.User, error) {
-	var user models.User
-
-	if err := us.db.QueryRow(queries.SelectUserByEmail, sql.Named("email", loginReq.Email)).Scan(&user.Id, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt); err != nil {
+	user, err := scanUser(us.db.QueryRow(queries.SelectUserByEmail, sql.Named("email", loginReq.Email)))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// Spend the same time as a wrong password so response times don't reveal registered emails
+			utils.VerifyPassword(loginReq.Password, dummyPasswordHash)
 			return nil, errors.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("register user: %w", err)
@@ -71,9 +75,8 @@ func (us *UserService) AuthenticateUser(loginReq models.LoginRequest) (*models.U
 }
 
 func (us *UserService) GetUserByEmail(email string) (*models.User, error) {
-	var user models.User
-
-	if err := us.db.QueryRow(queries.SelectUserByEmail, sql.Named("email", email)).Scan(&user.Id, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt); err != nil {
+	user, err := scanUser(us.db.QueryRow(queries.SelectUserByEmail, sql.Named("email", email)))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors.ErrUserNotFound
 		}
@@ -83,6 +86,18 @@ func (us *UserService) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+func (us *UserService) GetUserById(uid uuid.UUID) (*models.User, error) {
+	user, err := scanUser(us.db.QueryRow(queries.SelectUserById, sql.Named("id", uid)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	return &user, nil
+}
+
 func (us *UserService) UpdatePassword(email, password string) error {
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
@@ -97,3 +112,30 @@ func (us *UserService) UpdatePassword(email, password string) error {
 
 	return nil
 }
+
+// MarkEmailVerified sets email_verified_at once, verifying an already verified email keeps the first date
+func (us *UserService) MarkEmailVerified(email string) error {
+	if _, err := us.db.Exec(queries.MarkUserEmailVerified,
+		sql.Named("email_verified_at", time.Now()),
+		sql.Named("email", email)); err != nil {
+		return fmt.Errorf("mark email verified: %w", err)
+	}
+
+	return nil
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+
+	err := row.Scan(
+		&user.Id,
+		&user.Name,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.CreatedAt,
+		&user.EmailVerifiedAt,
+	)
+
+	return user, err
+}
@@ -1,17 +1,29 @@
 package queries
 
 import (
+	"database/sql"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
 	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/utils"
 )
 
+// QueryBuilder writes the SQL text and collects the values as named arguments,
+// user input must never be written into the query itself
 type QueryBuilder struct {
 	strings.Builder
+	args []any
 }
 
+// Columns hotels can be sorted by
+var sortableColumns = []string{"name", "city", "country", "price_per_night", "rating", "created_at"}
+
 type ConditionType int
 
 const (
@@ -20,6 +32,8 @@ const (
 	ConditionBetween
 	ConditionGt
 	ConditionIn
+	ConditionAvailable
+	ConditionFeatures
 )
 
 type Condition struct {
@@ -28,10 +42,98 @@ type Condition struct {
 	Value    string
 	Min, Max int
 	Values   []string
+	// Used by ConditionAvailable
+	Nights   []string
+	Capacity int
+	Rooms    int
+}
+
+// BuildHotelsQueryWithParams returns the hotel listing query and the arguments to run it with
+func BuildHotelsQueryWithParams(params models.HotelFilterParams) (string, []any) {
+	var query QueryBuilder
+
+	query.WriteString("SELECT " + hotelColumns + "FROM hotels ")
+	query.buildWhereClause(hotelConditions(params)...)
+	query.buildOrderByClause(params.SortBy, params.SortOrder)
+	query.buildPagination(params.Page, params.PageSize)
+
+	return query.String(), query.args
+}
+
+// BuildHotelsCursorQueryWithParams returns the keyset paginated hotel listing query.
+// It fetches one hotel more than the page size so the caller knows whether a next page exists.
+func BuildHotelsCursorQueryWithParams(params models.HotelFilterParams, cursor *models.HotelCursor) (string, []any, error) {
+	var query QueryBuilder
+
+	query.WriteString("SELECT " + hotelColumns + "FROM hotels ")
+	query.buildWhereClause(hotelConditions(params)...)
+
+	if cursor != nil {
+		if err := query.buildKeysetClause(params.SortBy, params.SortOrder, *cursor); err != nil {
+			return "", nil, err
+		}
+	}
+
+	query.buildOrderByClause(params.SortBy, params.SortOrder)
+	query.buildPagination(1, params.PageSize+1)
+
+	return query.String(), query.args, nil
 }
 
-func BuildHotelsQueryWithParams(params models.HotelFilterParams) string {
+// BuildHotelsCountQueryWithParams returns the query counting every hotel that matches the filters, ignoring pagination
+func BuildHotelsCountQueryWithParams(params models.HotelFilterParams) (string, []any) {
+	var query QueryBuilder
+
+	query.WriteString("SELECT COUNT(*) FROM hotels ")
+	query.buildWhereClause(hotelConditions(params)...)
+
+	return query.String(), query.args
+}
+
+type Facet int
+
+const (
+	FacetCity Facet = iota
+	FacetCountry
+	FacetFeature
+	FacetRating
+)
+
+// BuildHotelFacetQueryWithParams returns a query selecting (value, count) pairs of facet for the hotels matching params.
+// The filter on the facet itself is dropped so that the other values of the facet are still counted.
+func BuildHotelFacetQueryWithParams(params models.HotelFilterParams, facet Facet) (string, []any) {
 	var query QueryBuilder
+
+	switch facet {
+	case FacetCity:
+		params.City = ""
+		query.WriteString("SELECT city, COUNT(*) FROM hotels ")
+		query.buildWhereClause(hotelConditions(params)...)
+		query.WriteString("GROUP BY city ")
+	case FacetCountry:
+		params.Country = ""
+		query.WriteString("SELECT country, COUNT(*) FROM hotels ")
+		query.buildWhereClause(hotelConditions(params)...)
+		query.WriteString("GROUP BY country ")
+	case FacetFeature:
+		params.Features = nil
+		query.WriteString("SELECT f.name, COUNT(*) FROM hotel_features hf JOIN features f ON f.id = hf.feature_id ")
+		query.WriteString("WHERE hf.hotel_id IN (SELECT id FROM hotels ")
+		query.buildWhereClause(hotelConditions(params)...)
+		query.WriteString(") GROUP BY f.name ")
+	case FacetRating:
+		params.MinRating = 0
+		query.WriteString("SELECT CAST(FLOOR(rating) AS INT), COUNT(*) FROM hotels ")
+		query.buildWhereClause(hotelConditions(params)...)
+		query.WriteString("GROUP BY FLOOR(rating) ")
+	}
+
+	query.WriteString("ORDER BY 2 DESC, 1 ")
+
+	return query.String(), query.args
+}
+
+func hotelConditions(params models.HotelFilterParams) []Condition {
 	conditions := []Condition{
 		{
 			Type:  ConditionLike,
@@ -61,26 +163,80 @@ func BuildHotelsQueryWithParams(params models.HotelFilterParams) string {
 		},
 	}
 
-	for _, f := range params.Features {
+	if len(params.Features) > 0 {
 		conditions = append(conditions, Condition{
-			Type:  ConditionLike,
-			Field: "features",
-			Value: f,
+			Type:   ConditionFeatures,
+			Field:  "id",
+			Value:  params.FeatureMatch,
+			Values: params.Features,
 		})
 	}
 
-	query.WriteString("SELECT * FROM hotels ")
-	query.buildWhereClause(conditions...)
-	query.buildOrderByClause(params.SortBy, params.SortOrder)
-	query.buildPagination(1, 3)
+	if params.HasStay() || params.Guests > 1 || params.Rooms > 1 {
+		conditions = append(conditions, Condition{
+			Type:     ConditionAvailable,
+			Field:    "id",
+			Nights:   params.StayNights(),
+			Capacity: params.MinRoomCapacity(),
+			Rooms:    params.Rooms,
+		})
+	}
+
+	return conditions
+}
 
-	return query.String()
+// bind adds value as a named argument and returns its placeholder
+func (qb *QueryBuilder) bind(value any) string {
+	name := fmt.Sprintf("p%d", len(qb.args))
+	qb.args = append(qb.args, sql.Named(name, value))
+
+	return "@" + name
 }
 
+// SortColumn returns the whitelisted column for sortBy, falling back to name
+func SortColumn(sortBy string) string {
+	column := utils.CamelToSnakeCase(sortBy)
+	if !slices.Contains(sortableColumns, column) {
+		return "name"
+	}
+
+	return column
+}
+
+// SortDirection returns DESC for "desc" in any case, ASC otherwise
+func SortDirection(sortOrder string) string {
+	if strings.EqualFold(sortOrder, "desc") {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// id breaks ties so that the order is stable across pages
 func (qb *QueryBuilder) buildOrderByClause(sortBy, sortOrder string) {
-	sortBy = utils.CamelToSnakeCase(sortBy)
+	column, direction := SortColumn(sortBy), SortDirection(sortOrder)
+
+	qb.WriteString(fmt.Sprintf("ORDER BY %v %v, id %v ", column, direction, direction))
+}
+
+// buildKeysetClause keeps the hotels that come after the cursor in the sort order, it must follow buildWhereClause
+func (qb *QueryBuilder) buildKeysetClause(sortBy, sortOrder string, cursor models.HotelCursor) error {
+	column, direction := SortColumn(sortBy), SortDirection(sortOrder)
+
+	value, err := cursorValue(column, cursor.Value)
+	if err != nil {
+		return err
+	}
+
+	operator := ">"
+	if direction == "DESC" {
+		operator = "<"
+	}
+
+	v, id := qb.bind(value), qb.bind(cursor.Id)
+	qb.WriteString(fmt.Sprintf("AND (%v %v %v OR (%v = %v AND id %v %v)) ", column, operator, v, column, v, operator, id))
 
-	qb.WriteString(fmt.Sprintf("ORDER BY %v %v ", sortBy, sortOrder))
+	return nil
 }
 
 func (qb *QueryBuilder) buildWhereClause(conditions ...Condition) {
@@ -100,20 +256,24 @@ func (qb *QueryBuilder) buildWhereClause(conditions ...Condition) {
 			qb.gtClause(c)
 		case ConditionIn:
 			qb.inClause(c)
+		case ConditionAvailable:
+			qb.availableClause(c)
+		case ConditionFeatures:
+			qb.featuresClause(c)
 		}
 	}
 }
 
 func (qb *QueryBuilder) likeClause(c Condition) {
-	qb.WriteString(fmt.Sprintf("%v LIKE '%%%v%%' ", c.Field, c.Value))
+	qb.WriteString(fmt.Sprintf("%v LIKE %v ", c.Field, qb.bind("%"+escapeLike(c.Value)+"%")))
 }
 
 func (qb *QueryBuilder) betweenClause(c Condition) {
-	qb.WriteString(fmt.Sprintf("%s BETWEEN %d AND %d ", c.Field, c.Min, c.Max))
+	qb.WriteString(fmt.Sprintf("%s BETWEEN %v AND %v ", c.Field, qb.bind(c.Min), qb.bind(c.Max)))
 }
 
 func (qb *QueryBuilder) gtClause(c Condition) {
-	qb.WriteString(fmt.Sprintf("%v >= %v ", c.Field, c.Min))
+	qb.WriteString(fmt.Sprintf("%v >= %v ", c.Field, qb.bind(c.Min)))
 }
 
 func (qb *QueryBuilder) inClause(c Condition) {
@@ -124,15 +284,88 @@ func (qb *QueryBuilder) inClause(c Condition) {
 			sb.WriteRune(',')
 		}
 
-		sb.WriteString(fmt.Sprintf("'%v'", v))
+		sb.WriteString(qb.bind(v))
 	}
 
 	qb.WriteString(fmt.Sprintf("%v IN (%v) ", c.Field, sb.String()))
 }
 
+// featuresClause keeps hotels having any or, by default, all of the features by exact name
+func (qb *QueryBuilder) featuresClause(c Condition) {
+	var sb strings.Builder
+
+	for i, v := range c.Values {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+
+		sb.WriteString(qb.bind(v))
+	}
+
+	qb.WriteString(fmt.Sprintf("%v IN (SELECT hf.hotel_id FROM hotel_features hf JOIN features f ON f.id = hf.feature_id WHERE f.name IN (%v) ", c.Field, sb.String()))
+
+	if c.Value != models.FeatureMatchAny {
+		qb.WriteString(fmt.Sprintf("GROUP BY hf.hotel_id HAVING COUNT(DISTINCT f.id) = %v", qb.bind(len(c.Values))))
+	}
+
+	qb.WriteString(") ")
+}
+
+// availableClause keeps hotels having a room type that fits the guests and
+// has enough free units on every night of the stay
+func (qb *QueryBuilder) availableClause(c Condition) {
+	rooms := qb.bind(c.Rooms)
+
+	qb.WriteString(fmt.Sprintf("%v IN (SELECT rt.hotel_id FROM room_types rt WHERE rt.capacity >= %v AND rt.unit_count >= %v ", c.Field, qb.bind(c.Capacity), rooms))
+
+	if len(c.Nights) > 0 {
+		var sb strings.Builder
+
+		for i, night := range c.Nights {
+			if i > 0 {
+				sb.WriteRune(',')
+			}
+
+			sb.WriteString(fmt.Sprintf("(CAST(%v AS DATE))", qb.bind(night)))
+		}
+
+		qb.WriteString(fmt.Sprintf("AND NOT EXISTS (SELECT 1 FROM (VALUES %v) AS n(night) ", sb.String()))
+		qb.WriteString("WHERE rt.unit_count - (SELECT COUNT(*) FROM reservations r WHERE r.room_type_id = rt.id AND r.status <> 'cancelled' ")
+		qb.WriteString(fmt.Sprintf("AND r.check_in_date <= n.night AND r.check_out_date > n.night) < %v) ", rooms))
+	}
+
+	qb.WriteString(") ")
+}
+
 func (qb *QueryBuilder) buildPagination(page, pageSize int) {
-	qb.WriteString(fmt.Sprintf("OFFSET %v ROWS ", (page-1)*pageSize))
-	qb.WriteString(fmt.Sprintf("FETCH NEXT %v ROWS ONLY ", pageSize))
+	qb.WriteString(fmt.Sprintf("OFFSET %v ROWS ", qb.bind((page-1)*pageSize)))
+	qb.WriteString(fmt.Sprintf("FETCH NEXT %v ROWS ONLY ", qb.bind(pageSize)))
+}
+
+// cursorValue converts the value stored in a cursor to the type of column,
+// a value that doesn't fit the column is rejected before it reaches the database
+func cursorValue(column, value string) (any, error) {
+	switch column {
+	case "created_at":
+		createdAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, errors.ErrInvalidCursor
+		}
+		return createdAt, nil
+	case "price_per_night", "rating":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, errors.ErrInvalidCursor
+		}
+		return number, nil
+	default:
+		return value, nil
+	}
+}
+
+// escapeLike escapes the LIKE wildcards so they are matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer("[", "[[]", "%", "[%]", "_", "[_]").Replace(value)
 }
 
 const InsertHotelQuery = `
@@ -146,14 +379,61 @@ INSERT INTO hotels (
 			price_per_night,
 			rating,
 			phone_number,
-			features,
 			created_at
 		)
 		ValueS (
-			@id, @name, @description, @city, @country, @image_url, @price_per_night, @rating, @phone_number, @features, @created_at
+			@id, @name, @description, @city, @country, @image_url, @price_per_night, @rating, @phone_number, @created_at
 		);
 `
 
 const SelectHotelById = `
-SELECT * FROM hotels WHERE id = @id;
+SELECT ` + hotelColumns + ` FROM hotels WHERE id = @id;
+`
+
+// hotelColumns selects a hotel with its feature names joined by commas
+const hotelColumns = `id, name, description, city, country, image_url, price_per_night, rating, phone_number,
+ISNULL((SELECT STRING_AGG(f.name, ',') FROM hotel_features hf JOIN features f ON f.id = hf.feature_id WHERE hf.hotel_id = hotels.id), '') AS features,
+created_at `
+
+const UpdateHotel = `
+UPDATE hotels
+SET name = @name,
+	description = @description,
+	city = @city,
+	country = @country,
+	image_url = @image_url,
+	price_per_night = @price_per_night,
+	rating = @rating,
+	phone_number = @phone_number
+WHERE id = @id;
+`
+
+const DeleteHotel = `
+DELETE FROM hotels WHERE id = @id;
+`
+
+const CountHotelReservations = `
+SELECT COUNT(*) FROM reservations WHERE hotel_id = @hotel_id;
+`
+
+const DeleteHotelFeatures = `
+DELETE FROM hotel_features WHERE hotel_id = @hotel_id;
+`
+
+const InsertFeature = `
+IF NOT EXISTS (SELECT 1 FROM features WHERE name = @name)
+	INSERT INTO features (name) VALUES (@name);
+`
+
+const InsertHotelFeature = `
+INSERT INTO hotel_features (hotel_id, feature_id)
+SELECT @hotel_id, id FROM features WHERE name = @name;
+`
+
+const CountHotels = `
+SELECT COUNT(*) FROM hotels;
+`
+
+const CountHotelsById = `
+SELECT COUNT(*) FROM hotels WHERE id = @id;
 `
@@ -0,0 +1,44 @@
+package queries
+
+const InsertHotelStaff = `
+	IF NOT EXISTS (SELECT 1 FROM hotel_staff WHERE hotel_id = @hotel_id AND user_id = @user_id)
+		INSERT INTO hotel_staff (hotel_id, user_id, created_at) VALUES (@hotel_id, @user_id, @created_at);
+`
+
+const DeleteHotelStaff = `
+	DELETE FROM hotel_staff
+	WHERE hotel_id = @hotel_id AND user_id = @user_id;
+`
+
+const CountHotelStaff = `
+	SELECT COUNT(*)
+	FROM hotel_staff
+	WHERE hotel_id = @hotel_id AND user_id = @user_id;
+`
+
+const SelectHotelsByStaff = `
+SELECT ` + hotelColumns + ` FROM hotels
+WHERE id IN (SELECT hotel_id FROM hotel_staff WHERE user_id = @user_id)
+ORDER BY name;
+`
+
+const SelectUserRoleById = `
+	SELECT role
+	FROM users
+	WHERE id = @id;
+`
+
+// Plain users are promoted when they are assigned to their first hotel
+const PromoteUserToHotelManager = `
+	UPDATE users
+	SET role = @role
+	WHERE id = @id AND role = @from_role;
+`
+
+// Managers go back to being plain users when they are removed from their last hotel
+const DemoteHotelManager = `
+	UPDATE users
+	SET role = @role
+	WHERE id = @id AND role = @from_role
+		AND NOT EXISTS (SELECT 1 FROM hotel_staff WHERE user_id = @id);
+`
@@ -14,6 +14,12 @@ const InsertUser = `
 		);
 `
 
+const MarkUserEmailVerified = `
+	UPDATE users
+	SET email_verified_at = @email_verified_at
+	WHERE email = @email AND email_verified_at IS NULL;
+`
+
 const UpdateUserPasswordByEmail = `
 	UPDATE users
 	SET password_hash = @password_hash
@@ -0,0 +1,31 @@
+package queries
+
+// SelectLoginAttemptForUpdate locks the row, or the key range when there is no row yet,
+// so concurrent failures of the same subject are counted one after another
+const SelectLoginAttemptForUpdate = `
+	SELECT scope, subject, failures, last_failed_at, locked_until
+	FROM login_attempts WITH (UPDLOCK, HOLDLOCK)
+	WHERE scope = @scope AND subject = @subject;
+`
+
+const UpsertLoginAttempt = `
+	IF EXISTS (SELECT 1 FROM login_attempts WHERE scope = @scope AND subject = @subject)
+		UPDATE login_attempts
+		SET failures = @failures, last_failed_at = @last_failed_at, locked_until = @locked_until
+		WHERE scope = @scope AND subject = @subject;
+	ELSE
+		INSERT INTO login_attempts (scope, subject, failures, last_failed_at, locked_until)
+			VALUES (@scope, @subject, @failures, @last_failed_at, @locked_until);
+`
+
+const SelectLoginLockout = `
+	SELECT MAX(locked_until)
+	FROM login_attempts
+	WHERE ((scope = @account_scope AND subject = @email) OR (scope = @ip_scope AND subject = @ip))
+		AND locked_until > @now;
+`
+
+const DeleteLoginAttempt = `
+	DELETE FROM login_attempts
+	WHERE scope = @scope AND subject = @subject;
+`
@@ -0,0 +1,52 @@
+package queries
+
+const SelectUserMFA = `
+	SELECT user_id, secret, last_used_step, confirmed_at, created_at
+	FROM user_mfa
+	WHERE user_id = @user_id;
+`
+
+// UpsertUserMFA replaces an unconfirmed enrollment, a confirmed one is left untouched
+const UpsertUserMFA = `
+	IF EXISTS (SELECT 1 FROM user_mfa WHERE user_id = @user_id)
+		UPDATE user_mfa
+		SET secret = @secret, last_used_step = 0, created_at = @created_at
+		WHERE user_id = @user_id AND confirmed_at IS NULL;
+	ELSE
+		INSERT INTO user_mfa (user_id, secret, created_at)
+			VALUES (@user_id, @secret, @created_at);
+`
+
+const ConfirmUserMFA = `
+	UPDATE user_mfa
+	SET confirmed_at = @confirmed_at, last_used_step = @step
+	WHERE user_id = @user_id AND confirmed_at IS NULL;
+`
+
+// UseMFAStep only matches a step after the last accepted one, so a TOTP code works once
+const UseMFAStep = `
+	UPDATE user_mfa
+	SET last_used_step = @step
+	WHERE user_id = @user_id AND last_used_step < @step;
+`
+
+const DeleteUserMFA = `
+	DELETE FROM user_mfa
+	WHERE user_id = @user_id;
+`
+
+const InsertRecoveryCode = `
+	INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+		VALUES (@id, @user_id, @code_hash, @created_at);
+`
+
+const UseRecoveryCode = `
+	UPDATE mfa_recovery_codes
+	SET used_at = @used_at
+	WHERE user_id = @user_id AND code_hash = @code_hash AND used_at IS NULL;
+`
+
+const DeleteRecoveryCodes = `
+	DELETE FROM mfa_recovery_codes
+	WHERE user_id = @user_id;
+`
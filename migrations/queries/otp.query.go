@@ -1,25 +1,45 @@
 package queries
 
 const InsertOTPToken = `
-	INSERT INTO otp_tokens (id, email, token_hash, expires_at, created_at)
+	INSERT INTO otp_tokens (id, email, token_hash, expires_at, created_at, purpose)
 		VALUES (
 			@id,
 			@email,
 			@token_hash,
 			@expires_at,
-			@created_at
+			@created_at,
+			@purpose
 		)
 `
 
-const SelectOTPToken = `
-	SELECT id, email, token_hash, expires_at, created_at
+const SelectOTPTokenByEmail = `
+	SELECT id, email, token_hash, expires_at, created_at, attempts, purpose
 	FROM otp_tokens
-	WHERE email = @email AND token_hash = @token_hash
+	WHERE email = @email AND purpose = @purpose
+`
+
+// CountOTPAttempt only matches while attempts are left,
+// so concurrent guesses can't go over the limit
+const CountOTPAttempt = `
+	UPDATE otp_tokens
+	SET attempts = attempts + 1
+	WHERE id = @id AND attempts < @max_attempts
 `
 
 const DeleteOTPToken = `
 	DELETE FROM otp_tokens
-	WHERE token_hash = @token_hash
+	WHERE id = @id
+`
+
+// ConsumeOTPToken deletes the token only if it matches and hasn't expired, so it can be used once
+const ConsumeOTPToken = `
+	DELETE FROM otp_tokens
+	WHERE email = @email AND purpose = @purpose AND token_hash = @token_hash AND expires_at > @now
+`
+
+const DeleteOTPTokenByEmail = `
+	DELETE FROM otp_tokens
+	WHERE email = @email AND purpose = @purpose
 `
 
 const SelectUserIdByEmail = `
@@ -0,0 +1,35 @@
+package queries
+
+const InsertRole = `
+	IF NOT EXISTS (SELECT 1 FROM roles WHERE name = @name)
+		INSERT INTO roles (name) VALUES (@name);
+`
+
+const InsertPermission = `
+	IF NOT EXISTS (SELECT 1 FROM permissions WHERE name = @name)
+		INSERT INTO permissions (name) VALUES (@name);
+`
+
+const InsertRolePermission = `
+	IF NOT EXISTS (SELECT 1 FROM role_permissions WHERE role = @role AND permission = @permission)
+		INSERT INTO role_permissions (role, permission) VALUES (@role, @permission);
+`
+
+const SelectPermissionsByRole = `
+	SELECT permission
+	FROM role_permissions
+	WHERE role = @role;
+`
+
+// Replaces the hardcoded CHECK constraint on users.role with a foreign key to roles,
+// it must run after the roles are seeded
+const AddUserRoleForeignKey = `
+	IF EXISTS (SELECT * FROM sys.check_constraints WHERE name = 'CHK_role')
+		ALTER TABLE users DROP CONSTRAINT CHK_role;
+
+	IF NOT EXISTS (SELECT * FROM sys.foreign_keys WHERE name = 'FK_user_role')
+	BEGIN
+		ALTER TABLE users ALTER COLUMN role NVARCHAR(30) NOT NULL;
+		ALTER TABLE users ADD CONSTRAINT FK_user_role FOREIGN KEY (role) REFERENCES roles(name);
+	END
+`
@@ -1,30 +1,48 @@
 package queries
 
-// TODO: Upsert
 const InsertRefreshToken string = `
-INSERT INTO refresh_tokens (id, user_id, token_hash, created_at, expires_at)
-	VALUES (@id, @user_id, @token_hash, @created_at, @expires_at)
+INSERT INTO refresh_tokens (id, user_id, token_hash, created_at, expires_at, ip, user_agent, os, browser, device, family_id)
+	VALUES (@id, @user_id, @token_hash, @created_at, @expires_at, @ip, @user_agent, @os, @browser, @device, @family_id)
 `
 
-const ExpiryCheck string = `
-SELECT expires_at FROM refresh_tokens
-WHERE user_id = @user_id;
-`
-
-const UpdateRefreshToken string = `
+// RotateRefreshToken only matches a token that has not been rotated yet,
+// so one of two concurrent refreshes with the same token sees it as reused
+const RotateRefreshToken string = `
 UPDATE refresh_tokens
-SET token_hash = @token_hash, 
-    created_at = @created_at,
-    expires_at = @expires_at
-    WHERE user_id = @user_id;
+SET rotated_at = @rotated_at
+    WHERE id = @id AND rotated_at IS NULL;
 `
 
 const SelectRefreshToken string = `
-SELECT * FROM refresh_tokens
+SELECT ` + refreshTokenColumns + ` FROM refresh_tokens
 WHERE token_hash = @token_hash;
 `
 
+const SelectRefreshTokensByUserId string = `
+SELECT ` + refreshTokenColumns + ` FROM refresh_tokens
+WHERE user_id = @user_id AND rotated_at IS NULL AND expires_at > @now
+ORDER BY created_at DESC;
+`
+
+// DeleteRefreshToken ends the session of the token, every token of its family is deleted
 const DeleteRefreshToken string = `
 DELETE FROM refresh_tokens
+WHERE user_id = @user_id AND family_id = (SELECT family_id FROM refresh_tokens WHERE id = @id AND user_id = @user_id);
+`
+
+const DeleteRefreshTokenFamily string = `
+DELETE FROM refresh_tokens
+WHERE family_id = @family_id;
+`
+
+const DeleteRefreshTokensByUserId string = `
+DELETE FROM refresh_tokens
 WHERE user_id = @user_id;
 `
+
+const DeleteExpiredRefreshTokens string = `
+DELETE FROM refresh_tokens
+WHERE user_id = @user_id AND expires_at <= @now;
+`
+
+const refreshTokenColumns = `id, user_id, token_hash, created_at, expires_at, ip, user_agent, os, browser, device, family_id, rotated_at `
@@ -0,0 +1,69 @@
+package queries
+
+// UPDLOCK + HOLDLOCK keeps the room type row locked until the transaction ends,
+// so concurrent bookings for the same room type are serialized
+const SelectRoomTypeForReservation = `
+	SELECT capacity, base_price, unit_count
+	FROM room_types WITH (UPDLOCK, HOLDLOCK)
+	WHERE id = @id AND hotel_id = @hotel_id;
+`
+
+const SelectOverlappingReservations = `
+	SELECT check_in_date, check_out_date
+	FROM reservations
+	WHERE room_type_id = @room_type_id
+		AND status <> 'cancelled'
+		AND check_in_date < @check_out_date
+		AND check_out_date > @check_in_date;
+`
+
+const InsertReservation = `
+	INSERT INTO reservations (
+		id,
+		user_id,
+		hotel_id,
+		room_type_id,
+		check_in_date,
+		check_out_date,
+		guest_count,
+		total_price,
+		status,
+		created_at
+	)
+	VALUES (
+		@id, @user_id, @hotel_id, @room_type_id, @check_in_date, @check_out_date, @guest_count, @total_price, @status, @created_at
+	);
+`
+
+const SelectReservationsByUserId = `
+	SELECT id, user_id, hotel_id, room_type_id, check_in_date, check_out_date, guest_count, total_price, status, created_at
+	FROM reservations
+	WHERE user_id = @user_id
+	ORDER BY check_in_date DESC;
+`
+
+const SelectReservationById = `
+	SELECT id, user_id, hotel_id, room_type_id, check_in_date, check_out_date, guest_count, total_price, status, created_at
+	FROM reservations
+	WHERE id = @id AND user_id = @user_id;
+`
+
+// Only reservations that aren't cancelled yet are updated, so concurrent cancellations can't both succeed
+const CancelReservation = `
+	UPDATE reservations
+	SET status = @status
+	WHERE id = @id AND user_id = @user_id AND status <> @status;
+`
+
+const SelectAllReservations = `
+	SELECT id, user_id, hotel_id, room_type_id, check_in_date, check_out_date, guest_count, total_price, status, created_at
+	FROM reservations
+	WHERE @hotel_id IS NULL OR hotel_id = @hotel_id
+	ORDER BY check_in_date DESC;
+`
+
+const SelectAnyReservationById = `
+	SELECT id, user_id, hotel_id, room_type_id, check_in_date, check_out_date, guest_count, total_price, status, created_at
+	FROM reservations
+	WHERE id = @id;
+`
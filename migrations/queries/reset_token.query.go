@@ -0,0 +1,19 @@
+package queries
+
+const InsertResetToken string = `
+INSERT INTO reset_tokens (jti_hash, email, expires_at, created_at)
+	VALUES (@jti_hash, @email, @expires_at, @created_at)
+`
+
+// ConsumeResetToken only matches an unused and unexpired token,
+// so of two concurrent password changes with the same token only one succeeds
+const ConsumeResetToken string = `
+UPDATE reset_tokens
+SET used_at = @now
+    WHERE jti_hash = @jti_hash AND email = @email AND used_at IS NULL AND expires_at > @now;
+`
+
+const DeleteExpiredResetTokens string = `
+DELETE FROM reset_tokens
+WHERE email = @email AND expires_at <= @now;
+`
@@ -0,0 +1,53 @@
+package queries
+
+const InsertRoomType = `
+	INSERT INTO room_types (
+		id,
+		hotel_id,
+		name,
+		description,
+		capacity,
+		bed_configuration,
+		base_price,
+		unit_count,
+		created_at
+	)
+	VALUES (
+		@id, @hotel_id, @name, @description, @capacity, @bed_configuration, @base_price, @unit_count, @created_at
+	);
+`
+
+const SelectRoomTypesByHotelId = `
+	SELECT id, hotel_id, name, description, capacity, bed_configuration, base_price, unit_count, created_at
+	FROM room_types
+	WHERE hotel_id = @hotel_id
+	ORDER BY base_price;
+`
+
+const SelectRoomTypeById = `
+	SELECT id, hotel_id, name, description, capacity, bed_configuration, base_price, unit_count, created_at
+	FROM room_types
+	WHERE id = @id AND hotel_id = @hotel_id;
+`
+
+const UpdateRoomType = `
+	UPDATE room_types
+	SET name = @name,
+		description = @description,
+		capacity = @capacity,
+		bed_configuration = @bed_configuration,
+		base_price = @base_price,
+		unit_count = @unit_count
+	WHERE id = @id AND hotel_id = @hotel_id;
+`
+
+const DeleteRoomType = `
+	DELETE FROM room_types
+	WHERE id = @id AND hotel_id = @hotel_id;
+`
+
+const CountRoomTypeReservations = `
+	SELECT COUNT(*)
+	FROM reservations
+	WHERE room_type_id = @room_type_id;
+`
@@ -1,7 +1,15 @@
 package queries
 
 const SelectUserByEmail = `
-	SELECT *
+	SELECT ` + userColumns + `
 	FROM users
 	WHERE email = @email;
 	`
+
+const SelectUserById = `
+	SELECT ` + userColumns + `
+	FROM users
+	WHERE id = @id;
+	`
+
+const userColumns = `id, name, email, password_hash, role, created_at, email_verified_at`
@@ -0,0 +1,22 @@
+package queries
+
+const SelectUserByIdentity = `
+	SELECT ` + userColumns + `
+	FROM users
+	WHERE id = (
+		SELECT user_id
+		FROM user_identities
+		WHERE provider = @provider AND subject = @subject
+	);
+`
+
+const InsertUserIdentity = `
+	INSERT INTO user_identities (provider, subject, user_id, email, created_at)
+		VALUES (@provider, @subject, @user_id, @email, @created_at);
+`
+
+// InsertVerifiedUser creates a user who signed up through an identity provider, their email is verified by the provider
+const InsertVerifiedUser = `
+	INSERT INTO users (id, name, email, password_hash, role, created_at, email_verified_at)
+		VALUES (@id, @name, @email, @password_hash, @role, @created_at, @created_at);
+`
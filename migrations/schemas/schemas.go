@@ -1,7 +1,7 @@
 package schemas
 
 func All() []string {
-	return []string{users, refreshTokens, otpTokens, hotels}
+	return []string{roles, permissions, rolePermissions, users, migrateEmailVerification, refreshTokens, migrateRefreshTokenSessions, migrateRefreshTokenFamilies, otpTokens, migrateOTPAttempts, migrateOTPPurpose, resetTokens, loginAttempts, userMFA, mfaRecoveryCodes, userIdentities, hotels, features, hotelFeatures, migrateHotelFeatures, hotelStaff, roomTypes, reservations}
 }
 
 const refreshTokens string = `
@@ -9,12 +9,22 @@ IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='refresh_tokens' AND xtype='U
 BEGIN
     CREATE TABLE refresh_tokens (
         id UNIQUEIDENTIFIER PRIMARY KEY,
-        user_id UNIQUEIDENTIFIER NOT NULL UNIQUE,
+        user_id UNIQUEIDENTIFIER NOT NULL,
         token_hash NVARCHAR(255) NOT NULL UNIQUE,
         created_at DATETIME2 NOT NULL,
         expires_at DATETIME2 NOT NULL,
+        ip NVARCHAR(45) NOT NULL DEFAULT '',
+        user_agent NVARCHAR(512) NOT NULL DEFAULT '',
+        os NVARCHAR(50) NOT NULL DEFAULT '',
+        browser NVARCHAR(50) NOT NULL DEFAULT '',
+        device NVARCHAR(50) NOT NULL DEFAULT '',
+        family_id UNIQUEIDENTIFIER NOT NULL,
+        rotated_at DATETIME2 NULL,
         CONSTRAINT FK_user_id FOREIGN KEY (user_id) REFERENCES users(id)
     );
+
+    CREATE INDEX IX_refresh_tokens_user_id ON refresh_tokens(user_id);
+    CREATE INDEX IX_refresh_tokens_family_id ON refresh_tokens(family_id);
 END
 
 `
@@ -27,12 +37,12 @@ BEGIN
         name NVARCHAR(50) NOT NULL,
         email NVARCHAR(255) NOT NULL UNIQUE,
         password_hash NVARCHAR(MAX) NOT NULL,
-        role NVARCHAR(10) NOT NULL,
+        role NVARCHAR(30) NOT NULL,
         created_at DATETIME2 NOT NULL,
+        email_verified_at DATETIME2 NULL,
 
         CONSTRAINT CHK_name_length CHECK (LEN(name) >= 3),
         CONSTRAINT CHK_password_length CHECK (LEN(password_hash) >= 8),
-        CONSTRAINT CHK_role CHECK (role IN ('admin', 'user')),
         CONSTRAINT CHK_email_format CHECK (
             email LIKE '[A-Za-z0-9._%+-]%@[A-Za-z0-9.-]%.[A-Za-z][A-Za-z]%'
         )
@@ -46,10 +56,14 @@ IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='otp_tokens' AND xtype='U')
 BEGIN
     CREATE TABLE otp_tokens (
         id UNIQUEIDENTIFIER PRIMARY KEY,
-        email NVARCHAR(255) NOT NULL UNIQUE,
+        email NVARCHAR(255) NOT NULL,
         token_hash NVARCHAR(MAX) NOT NULL,
         expires_at DATETIME2 NOT NULL,
         created_at DATETIME2 NOT NULL,
+        attempts INT NOT NULL DEFAULT 0,
+        purpose NVARCHAR(30) NOT NULL DEFAULT 'password_reset',
+
+        CONSTRAINT UQ_otp_tokens_email_purpose UNIQUE (email, purpose),
 
          CHECK (
             email LIKE '[A-Za-z0-9._%+-]%@[A-Za-z0-9.-]%.[A-Za-z][A-Za-z]%'
@@ -60,8 +74,6 @@ END
 `
 
 const hotels string = `
-DROP TABLE IF EXISTS hotels;
-
 IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='hotels' AND xtype='U')
 BEGIN
     CREATE TABLE hotels (
@@ -74,7 +86,6 @@ BEGIN
         price_per_night DECIMAL(10, 2) NOT NULL,
         rating DECIMAL(2, 1) NOT NULL,
         phone_number NVARCHAR(20) NOT NULL,
-        features TEXT NOT NULL,
         created_at DATETIME2 NOT NULL,
 
         CONSTRAINT CHK_hotel_name_length CHECK (LEN(name) >= 3),
@@ -88,3 +99,338 @@ BEGIN
 END
 
 `
+
+const reservations string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='reservations' AND xtype='U')
+BEGIN
+    CREATE TABLE reservations (
+        id UNIQUEIDENTIFIER PRIMARY KEY,
+        user_id UNIQUEIDENTIFIER NOT NULL,
+        hotel_id UNIQUEIDENTIFIER NOT NULL,
+        room_type_id UNIQUEIDENTIFIER NOT NULL,
+        check_in_date DATE NOT NULL,
+        check_out_date DATE NOT NULL,
+        guest_count INT NOT NULL,
+        total_price DECIMAL(12, 2) NOT NULL,
+        status NVARCHAR(20) NOT NULL,
+        created_at DATETIME2 NOT NULL,
+
+        CONSTRAINT FK_reservation_user_id FOREIGN KEY (user_id) REFERENCES users(id),
+        CONSTRAINT FK_reservation_hotel_id FOREIGN KEY (hotel_id) REFERENCES hotels(id),
+        CONSTRAINT FK_reservation_room_type_id FOREIGN KEY (room_type_id) REFERENCES room_types(id),
+        CONSTRAINT CHK_reservation_dates CHECK (check_out_date > check_in_date),
+        CONSTRAINT CHK_reservation_guest_count CHECK (guest_count > 0),
+        CONSTRAINT CHK_reservation_total_price CHECK (total_price > 0),
+        CONSTRAINT CHK_reservation_status CHECK (status IN ('pending', 'paid', 'cancelled'))
+    );
+
+    CREATE INDEX IX_reservations_user_id ON reservations(user_id);
+    CREATE INDEX IX_reservations_room_type_dates ON reservations(room_type_id, check_in_date, check_out_date);
+END
+
+`
+
+const roomTypes string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='room_types' AND xtype='U')
+BEGIN
+    CREATE TABLE room_types (
+        id UNIQUEIDENTIFIER PRIMARY KEY,
+        hotel_id UNIQUEIDENTIFIER NOT NULL,
+        name NVARCHAR(100) NOT NULL,
+        description NVARCHAR(255) NOT NULL,
+        capacity INT NOT NULL,
+        bed_configuration NVARCHAR(100) NOT NULL,
+        base_price DECIMAL(10, 2) NOT NULL,
+        unit_count INT NOT NULL,
+        created_at DATETIME2 NOT NULL,
+
+        CONSTRAINT FK_room_type_hotel_id FOREIGN KEY (hotel_id) REFERENCES hotels(id) ON DELETE CASCADE,
+        CONSTRAINT UQ_room_type_hotel_name UNIQUE (hotel_id, name),
+        CONSTRAINT CHK_room_type_name_length CHECK (LEN(name) >= 3),
+        CONSTRAINT CHK_room_type_capacity CHECK (capacity > 0),
+        CONSTRAINT CHK_room_type_base_price_positive CHECK (base_price > 0),
+        CONSTRAINT CHK_room_type_unit_count CHECK (unit_count >= 0)
+    );
+END
+
+`
+
+const features string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='features' AND xtype='U')
+BEGIN
+    CREATE TABLE features (
+        id INT IDENTITY(1, 1) PRIMARY KEY,
+        name NVARCHAR(100) NOT NULL UNIQUE,
+
+        CONSTRAINT CHK_feature_name_length CHECK (LEN(name) >= 2),
+        CONSTRAINT CHK_feature_name_comma CHECK (CHARINDEX(',', name) = 0)
+    );
+END
+
+`
+
+const hotelFeatures string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='hotel_features' AND xtype='U')
+BEGIN
+    CREATE TABLE hotel_features (
+        hotel_id UNIQUEIDENTIFIER NOT NULL,
+        feature_id INT NOT NULL,
+
+        CONSTRAINT PK_hotel_features PRIMARY KEY (hotel_id, feature_id),
+        CONSTRAINT FK_hotel_feature_hotel_id FOREIGN KEY (hotel_id) REFERENCES hotels(id) ON DELETE CASCADE,
+        CONSTRAINT FK_hotel_feature_feature_id FOREIGN KEY (feature_id) REFERENCES features(id)
+    );
+
+    CREATE INDEX IX_hotel_features_feature_id ON hotel_features(feature_id);
+END
+
+`
+
+const hotelStaff string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='hotel_staff' AND xtype='U')
+BEGIN
+    CREATE TABLE hotel_staff (
+        hotel_id UNIQUEIDENTIFIER NOT NULL,
+        user_id UNIQUEIDENTIFIER NOT NULL,
+        created_at DATETIME2 NOT NULL,
+
+        CONSTRAINT PK_hotel_staff PRIMARY KEY (hotel_id, user_id),
+        CONSTRAINT FK_hotel_staff_hotel_id FOREIGN KEY (hotel_id) REFERENCES hotels(id) ON DELETE CASCADE,
+        CONSTRAINT FK_hotel_staff_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE INDEX IX_hotel_staff_user_id ON hotel_staff(user_id);
+END
+
+`
+
+// Moves the comma joined hotels.features column of older databases into features/hotel_features.
+// Dynamic SQL is needed because the batch would not compile once the column is gone.
+const migrateHotelFeatures string = `
+IF COL_LENGTH('hotels', 'features') IS NOT NULL
+BEGIN
+    EXEC('
+        INSERT INTO features (name)
+        SELECT DISTINCT TRIM(s.value)
+        FROM hotels CROSS APPLY STRING_SPLIT(CAST(hotels.features AS NVARCHAR(MAX)), '','') s
+        WHERE LEN(TRIM(s.value)) >= 2 AND TRIM(s.value) NOT IN (SELECT name FROM features);
+
+        INSERT INTO hotel_features (hotel_id, feature_id)
+        SELECT DISTINCT h.id, f.id
+        FROM hotels h
+            CROSS APPLY STRING_SPLIT(CAST(h.features AS NVARCHAR(MAX)), '','') s
+            JOIN features f ON f.name = TRIM(s.value);
+
+        ALTER TABLE hotels DROP COLUMN features;
+    ');
+END
+
+`
+
+const roles string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='roles' AND xtype='U')
+BEGIN
+    CREATE TABLE roles (
+        name NVARCHAR(30) PRIMARY KEY
+    );
+END
+
+`
+
+const permissions string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='permissions' AND xtype='U')
+BEGIN
+    CREATE TABLE permissions (
+        name NVARCHAR(50) PRIMARY KEY
+    );
+END
+
+`
+
+const rolePermissions string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='role_permissions' AND xtype='U')
+BEGIN
+    CREATE TABLE role_permissions (
+        role NVARCHAR(30) NOT NULL,
+        permission NVARCHAR(50) NOT NULL,
+
+        CONSTRAINT PK_role_permissions PRIMARY KEY (role, permission),
+        CONSTRAINT FK_role_permission_role FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE,
+        CONSTRAINT FK_role_permission_permission FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+    );
+END
+
+`
+
+// migrateRefreshTokenSessions drops the one session per user constraint of older databases
+// and adds the device columns
+const migrateRefreshTokenSessions string = `
+IF COL_LENGTH('refresh_tokens', 'user_agent') IS NULL
+BEGIN
+    DECLARE @constraint NVARCHAR(128) = (
+        SELECT kc.name
+        FROM sys.key_constraints kc
+            JOIN sys.index_columns ic ON ic.object_id = kc.parent_object_id AND ic.index_id = kc.unique_index_id
+            JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+        WHERE kc.parent_object_id = OBJECT_ID('refresh_tokens') AND kc.type = 'UQ' AND c.name = 'user_id'
+    );
+
+    IF @constraint IS NOT NULL
+        EXEC('ALTER TABLE refresh_tokens DROP CONSTRAINT ' + @constraint);
+
+    ALTER TABLE refresh_tokens ADD
+        ip NVARCHAR(45) NOT NULL DEFAULT '',
+        user_agent NVARCHAR(512) NOT NULL DEFAULT '',
+        os NVARCHAR(50) NOT NULL DEFAULT '',
+        browser NVARCHAR(50) NOT NULL DEFAULT '',
+        device NVARCHAR(50) NOT NULL DEFAULT '';
+
+    CREATE INDEX IX_refresh_tokens_user_id ON refresh_tokens(user_id);
+END
+
+`
+
+// migrateRefreshTokenFamilies starts a family for every existing session
+const migrateRefreshTokenFamilies string = `
+IF COL_LENGTH('refresh_tokens', 'family_id') IS NULL
+BEGIN
+    ALTER TABLE refresh_tokens ADD
+        family_id UNIQUEIDENTIFIER NULL,
+        rotated_at DATETIME2 NULL;
+
+    EXEC('
+        UPDATE refresh_tokens SET family_id = id;
+
+        ALTER TABLE refresh_tokens ALTER COLUMN family_id UNIQUEIDENTIFIER NOT NULL;
+
+        CREATE INDEX IX_refresh_tokens_family_id ON refresh_tokens(family_id);
+    ');
+END
+
+`
+
+const resetTokens string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='reset_tokens' AND xtype='U')
+BEGIN
+    CREATE TABLE reset_tokens (
+        jti_hash NVARCHAR(64) PRIMARY KEY,
+        email NVARCHAR(255) NOT NULL,
+        expires_at DATETIME2 NOT NULL,
+        used_at DATETIME2 NULL,
+        created_at DATETIME2 NOT NULL
+    );
+
+    CREATE INDEX IX_reset_tokens_email ON reset_tokens(email);
+END
+
+`
+
+const migrateOTPAttempts string = `
+IF COL_LENGTH('otp_tokens', 'attempts') IS NULL
+BEGIN
+    ALTER TABLE otp_tokens ADD attempts INT NOT NULL DEFAULT 0;
+END
+
+`
+
+// migrateEmailVerification treats the users registered before email verification as verified
+const migrateEmailVerification string = `
+IF COL_LENGTH('users', 'email_verified_at') IS NULL
+BEGIN
+    ALTER TABLE users ADD email_verified_at DATETIME2 NULL;
+
+    EXEC('UPDATE users SET email_verified_at = created_at');
+END
+
+`
+
+// migrateOTPPurpose lets an email have one code per purpose, the existing codes are password reset codes
+const migrateOTPPurpose string = `
+IF COL_LENGTH('otp_tokens', 'purpose') IS NULL
+BEGIN
+    DECLARE @constraint NVARCHAR(128) = (
+        SELECT kc.name
+        FROM sys.key_constraints kc
+            JOIN sys.index_columns ic ON ic.object_id = kc.parent_object_id AND ic.index_id = kc.unique_index_id
+            JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+        WHERE kc.parent_object_id = OBJECT_ID('otp_tokens') AND kc.type = 'UQ' AND c.name = 'email'
+    );
+
+    IF @constraint IS NOT NULL
+        EXEC('ALTER TABLE otp_tokens DROP CONSTRAINT ' + @constraint);
+
+    ALTER TABLE otp_tokens ADD purpose NVARCHAR(30) NOT NULL DEFAULT 'password_reset';
+
+    EXEC('ALTER TABLE otp_tokens ADD CONSTRAINT UQ_otp_tokens_email_purpose UNIQUE (email, purpose)');
+END
+
+`
+
+const loginAttempts string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='login_attempts' AND xtype='U')
+BEGIN
+    CREATE TABLE login_attempts (
+        scope NVARCHAR(10) NOT NULL,
+        subject NVARCHAR(255) NOT NULL,
+        failures INT NOT NULL,
+        last_failed_at DATETIME2 NOT NULL,
+        locked_until DATETIME2 NULL,
+
+        CONSTRAINT PK_login_attempts PRIMARY KEY (scope, subject)
+    );
+END
+
+`
+
+const userMFA string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='user_mfa' AND xtype='U')
+BEGIN
+    CREATE TABLE user_mfa (
+        user_id UNIQUEIDENTIFIER PRIMARY KEY,
+        secret NVARCHAR(255) NOT NULL,
+        last_used_step BIGINT NOT NULL DEFAULT 0,
+        confirmed_at DATETIME2 NULL,
+        created_at DATETIME2 NOT NULL,
+
+        CONSTRAINT FK_user_mfa_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+END
+
+`
+
+const mfaRecoveryCodes string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='mfa_recovery_codes' AND xtype='U')
+BEGIN
+    CREATE TABLE mfa_recovery_codes (
+        id UNIQUEIDENTIFIER PRIMARY KEY,
+        user_id UNIQUEIDENTIFIER NOT NULL,
+        code_hash NVARCHAR(64) NOT NULL,
+        used_at DATETIME2 NULL,
+        created_at DATETIME2 NOT NULL,
+
+        CONSTRAINT FK_mfa_recovery_code_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE INDEX IX_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+END
+
+`
+
+const userIdentities string = `
+IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='user_identities' AND xtype='U')
+BEGIN
+    CREATE TABLE user_identities (
+        provider NVARCHAR(50) NOT NULL,
+        subject NVARCHAR(255) NOT NULL,
+        user_id UNIQUEIDENTIFIER NOT NULL,
+        email NVARCHAR(255) NOT NULL,
+        created_at DATETIME2 NOT NULL,
+
+        CONSTRAINT PK_user_identities PRIMARY KEY (provider, subject),
+        CONSTRAINT FK_user_identities_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+    );
+
+    CREATE INDEX IX_user_identities_user_id ON user_identities(user_id);
+END
+
+`
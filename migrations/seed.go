@@ -5,17 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
 	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
 	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/schemas"
+	"github.com/google/uuid"
 )
 
+// Relative to the working directory, the app is started from the repository root
+const hotelsSeedPath = "mock/hotels.json"
+
+// Init migrates the database and seeds it with the mock hotels
 func Init(db *sql.DB) error {
-	err := createTables(db, schemas.All()...)
+	err := Migrate(db)
 	if err != nil {
-		return fmt.Errorf("failed to migrate: %w", err)
+		return err
 	}
 
 	err = addHotels(db)
@@ -26,8 +30,65 @@ func Init(db *sql.DB) error {
 	return nil
 }
 
+// Migrate creates the tables and the roles without seeding any hotels
+func Migrate(db *sql.DB) error {
+	err := createTables(db, schemas.All()...)
+	if err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	err = addRoles(db)
+	if err != nil {
+		return fmt.Errorf("failed to add roles: %w", err)
+	}
+
+	return nil
+}
+
+func addRoles(db *sql.DB) error {
+	for role, permissions := range models.DefaultRolePermissions {
+		_, err := db.Exec(queries.InsertRole, sql.Named("name", role))
+		if err != nil {
+			return fmt.Errorf("failed to insert role: %w", err)
+		}
+
+		for _, permission := range permissions {
+			_, err = db.Exec(queries.InsertPermission, sql.Named("name", permission))
+			if err != nil {
+				return fmt.Errorf("failed to insert permission: %w", err)
+			}
+
+			_, err = db.Exec(queries.InsertRolePermission,
+				sql.Named("role", role),
+				sql.Named("permission", permission),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert role permission: %w", err)
+			}
+		}
+	}
+
+	_, err := db.Exec(queries.AddUserRoleForeignKey)
+	if err != nil {
+		return fmt.Errorf("failed to add user role foreign key: %w", err)
+	}
+
+	return nil
+}
+
 func addHotels(db *sql.DB) error {
-	doc, err := os.ReadFile("c:/Users/AKIF/Desktop/workspace/hotel-booking-app/mock/hotels.json")
+	var count int
+	err := db.QueryRow(queries.CountHotels).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count hotels: %w", err)
+	}
+
+	// Hotels are seeded only once, reservations reference them
+	if count > 0 {
+		return nil
+	}
+
+	doc, err := os.ReadFile(hotelsSeedPath)
 	if err != nil {
 		return fmt.Errorf("failed to read hotels.json: %w", err)
 	}
@@ -49,12 +110,43 @@ func addHotels(db *sql.DB) error {
 			sql.Named("price_per_night", hotel.PricePerNight),
 			sql.Named("rating", hotel.Rating),
 			sql.Named("phone_number", hotel.PhoneNumber),
-			sql.Named("features", strings.Join(hotel.Features, ",")),
 			sql.Named("created_at", hotel.CreatedAt),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert hotel: %w", err)
 		}
+
+		for _, feature := range hotel.Features {
+			_, err = db.Exec(queries.InsertFeature, sql.Named("name", feature))
+			if err != nil {
+				return fmt.Errorf("failed to insert feature: %w", err)
+			}
+
+			_, err = db.Exec(queries.InsertHotelFeature,
+				sql.Named("hotel_id", hotel.Id),
+				sql.Named("name", feature),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert hotel feature: %w", err)
+			}
+		}
+
+		for _, roomType := range hotel.RoomTypes {
+			_, err = db.Exec(queries.InsertRoomType,
+				sql.Named("id", uuid.New()),
+				sql.Named("hotel_id", hotel.Id),
+				sql.Named("name", roomType.Name),
+				sql.Named("description", roomType.Description),
+				sql.Named("capacity", roomType.Capacity),
+				sql.Named("bed_configuration", roomType.BedConfiguration),
+				sql.Named("base_price", roomType.BasePrice),
+				sql.Named("unit_count", roomType.UnitCount),
+				sql.Named("created_at", hotel.CreatedAt),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert room type: %w", err)
+			}
+		}
 	}
 
 	return nil
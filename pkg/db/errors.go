@@ -0,0 +1,34 @@
+package db
+
+import (
+	"errors"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// SQL Server error numbers
+const (
+	uniqueConstraintViolation = 2627
+	uniqueIndexViolation      = 2601
+	constraintViolation       = 547
+)
+
+// IsUniqueViolation reports whether err is caused by a UNIQUE constraint or index
+func IsUniqueViolation(err error) bool {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr.Number == uniqueConstraintViolation || sqlErr.Number == uniqueIndexViolation
+	}
+
+	return false
+}
+
+// IsConstraintViolation reports whether err is caused by a FOREIGN KEY or CHECK constraint
+func IsConstraintViolation(err error) bool {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr.Number == constraintViolation
+	}
+
+	return false
+}
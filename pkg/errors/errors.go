@@ -17,4 +17,35 @@ var (
 	ErrInvalidAuthHeader    = errors.New("invalid auth header")
 	ErrInvalidToken         = errors.New("invalid token")
 	ErrMissingAuthHeader    = errors.New("missing auth header")
+	ErrHotelNotFound        = errors.New("hotel not found")
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrInvalidStayDates     = errors.New("invalid stay dates")
+	ErrAlreadyCancelled     = errors.New("reservation already cancelled")
+	ErrNoAvailability       = errors.New("no availability for the selected dates")
+	ErrRoomTypeNotFound     = errors.New("room type not found")
+	ErrRoomTypeNameTaken    = errors.New("room type name is already taken")
+	ErrRoomTypeInUse        = errors.New("room type has reservations")
+	ErrGuestLimitExceeded   = errors.New("guest count exceeds room capacity")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrHotelNameTaken       = errors.New("hotel name is already taken")
+	ErrHotelHasReservations = errors.New("hotel has reservations")
+	ErrNotHotelStaff        = errors.New("user is not assigned to the hotel")
+	ErrStaffRoleConflict    = errors.New("user already has another staff role")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrResetTokenUsed       = errors.New("reset token already used")
+	ErrInvalidOTP           = errors.New("invalid otp")
+	ErrOTPExpired           = errors.New("otp expired")
+	ErrOTPAttemptsExceeded  = errors.New("otp attempts exceeded")
+	ErrOTPCooldown          = errors.New("otp requested too recently")
+	ErrLoginLocked          = errors.New("too many failed login attempts")
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnrolled       = errors.New("two-factor authentication not enrolled")
+	ErrInvalidMFACode       = errors.New("invalid two-factor code")
+	ErrMagicLinkUsed        = errors.New("magic link already used or replaced")
+	ErrProviderNotFound     = errors.New("identity provider not found")
+	ErrOIDCExchangeFailed   = errors.New("authorization code exchange failed")
+	ErrInvalidIdToken       = errors.New("invalid id token")
+	ErrUnverifiedIdentity   = errors.New("identity provider email not verified")
+	ErrAccountLinkConflict  = errors.New("account with this email can't be linked")
 )
@@ -38,6 +38,7 @@ func (m *Manager) Send(email Email) error {
 
 	msg.SetHeader("To", email.To)
 	msg.SetHeader("From", sender)
+	msg.SetHeader("Subject", email.Subject)
 	msg.SetBody("text/html", email.HTML)
 
 	err := m.dialer.DialAndSend(msg)
@@ -76,3 +77,93 @@ func (m *Manager) ForgotPassword(to, otp string) error {
 
 	return nil
 }
+
+type verificationEmailData struct {
+	OTP string
+}
+
+func (m *Manager) VerifyEmail(to, otp string) error {
+	tmpl, err := template.ParseFiles("templates/email_verification.html")
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	err = tmpl.Execute(&body, verificationEmailData{OTP: otp})
+	if err != nil {
+		return err
+	}
+
+	email := Email{
+		To:      to,
+		Subject: "Verify your email address",
+		HTML:    body.String(),
+	}
+
+	err = m.Send(email)
+	if err != nil {
+		return fmt.Errorf("verify email: %w", err)
+	}
+
+	return nil
+}
+
+type unlockEmailData struct {
+	OTP string
+}
+
+func (m *Manager) UnlockAccount(to, otp string) error {
+	tmpl, err := template.ParseFiles("templates/account_unlock.html")
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	err = tmpl.Execute(&body, unlockEmailData{OTP: otp})
+	if err != nil {
+		return err
+	}
+
+	email := Email{
+		To:      to,
+		Subject: "Unlock your account",
+		HTML:    body.String(),
+	}
+
+	err = m.Send(email)
+	if err != nil {
+		return fmt.Errorf("unlock account email: %w", err)
+	}
+
+	return nil
+}
+
+type magicLinkEmailData struct {
+	Link string
+}
+
+func (m *Manager) MagicLink(to, link string) error {
+	tmpl, err := template.ParseFiles("templates/magic_link.html")
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	err = tmpl.Execute(&body, magicLinkEmailData{Link: link})
+	if err != nil {
+		return err
+	}
+
+	email := Email{
+		To:      to,
+		Subject: "Your login link",
+		HTML:    body.String(),
+	}
+
+	err = m.Send(email)
+	if err != nil {
+		return fmt.Errorf("magic link email: %w", err)
+	}
+
+	return nil
+}
@@ -11,8 +11,58 @@ const (
 	SuccessfullyLoggedOut      string = "Logged out successfully."
 	TokenExpired               string = "Your session has expired. Please log in again."
 	TokenNotFound              string = "Token not found. Please log in again."
-	SentOTPCode                string = "Check your inbox! We’ve just sent you a code to reset your password."
+	SentOTPCode                string = "If an account exists for this email, we’ve sent a code to reset your password."
 	InvalidToken               string = "Invalid Token" // ! change
 	InvalidAuthHeader          string = "Invalid Authorization Header"
 	InvalidOTP                 string = "Invalid OTP. Please try again."
+	OTPExpired                 string = "Your code has expired. Please request a new one."
+	OTPAttemptsExceeded        string = "Too many incorrect attempts. Please request a new code."
+	OTPCooldown                string = "Please wait a minute before requesting a new code."
+	SentVerificationCode       string = "If an unverified account exists for this email, we’ve sent a code to verify it."
+	EmailVerified              string = "Your email address has been verified."
+	InvalidCredentials         string = "Invalid email or password."
+	LoginLocked                string = "Too many failed login attempts. Try again later or unlock your account from your email."
+	SentUnlockCode             string = "If an account exists for this email, we’ve sent a code to unlock it."
+	AccountUnlocked            string = "Your account has been unlocked. You can now log in."
+	MFARequired                string = "Enter the code from your authenticator app to finish logging in."
+	MFAAlreadyEnabled          string = "Two-factor authentication is already enabled."
+	MFANotEnrolled             string = "Two-factor authentication is not set up for this account."
+	InvalidMFACode             string = "Invalid authentication code. Please try again."
+	MFAEnabled                 string = "Two-factor authentication enabled. Store your recovery codes somewhere safe."
+	MFADisabled                string = "Two-factor authentication disabled."
+	SentMagicLink              string = "If an account exists for this email, a login link has been sent."
+	MagicLinkUsed              string = "This login link has already been used or a newer one was sent. Please request a new link."
+	ProviderNotFound           string = "This login provider is not supported."
+	ExternalLoginFailed        string = "We couldn't log you in with this provider. Please try again."
+	UnverifiedIdentity         string = "Your email address is not verified by this provider."
+	AccountLinkConflict        string = "An account with this email already exists. Log in with your password and verify your email to use this provider."
+	HotelNotFound              string = "No hotel found with the given information."
+	ReservationNotFound        string = "No reservation found with the given information."
+	InvalidStayDates           string = "Check-out must be after check-in and check-in cannot be in the past."
+	NoAvailability             string = "Sorry, there are no rooms left for the selected dates."
+	HotelNameTaken             string = "A hotel with the same name already exists."
+	HotelHasReservations       string = "This hotel has reservations and cannot be deleted."
+	HotelCreated               string = "Hotel created successfully."
+	HotelUpdated               string = "Hotel updated successfully."
+	HotelDeleted               string = "Hotel deleted successfully."
+	RoomTypeNotFound           string = "No room type found with the given information."
+	RoomTypeNameTaken          string = "This hotel already has a room type with the same name."
+	RoomTypeInUse              string = "This room type has reservations and cannot be deleted."
+	RoomTypeCreated            string = "Room type created successfully."
+	RoomTypeUpdated            string = "Room type updated successfully."
+	RoomTypeDeleted            string = "Room type deleted successfully."
+	GuestLimitExceeded         string = "The number of guests exceeds the capacity of the selected room."
+	InvalidCursor              string = "The page cursor is invalid or does not match the sort order."
+	ReservationCreated         string = "Your reservation has been created."
+	ReservationCancelled       string = "Your reservation has been cancelled."
+	ReservationNotCancellable  string = "This reservation has already been cancelled."
+	NotHotelStaff              string = "You are not assigned to this hotel."
+	StaffRoleConflict          string = "This user already has another staff role."
+	StaffAssigned              string = "User assigned to the hotel successfully."
+	StaffRemoved               string = "User removed from the hotel successfully."
+	StaffNotFound              string = "This user is not assigned to the hotel."
+	SessionNotFound            string = "No session found with the given information."
+	SessionRevoked             string = "Session revoked successfully."
+	TokenReused                string = "This session was ended for your security. Please log in again."
+	ResetTokenUsed             string = "This password reset link has already been used. Please request a new code."
 )
@@ -0,0 +1,446 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/config"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+const (
+	// jwksRefreshInterval limits how often an unknown kid makes us download the issuer keys again
+	jwksRefreshInterval = time.Minute
+	// maxResponseSize limits what is read from an issuer
+	maxResponseSize = 1 << 20
+)
+
+// discovery is the part of the OpenID Provider Metadata we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider implements the authorization code flow of an OpenID Connect issuer.
+// The metadata is discovered on first use, so an issuer being down doesn't stop the server from starting.
+type oidcProvider struct {
+	config config.OIDCProviderConfig
+	client *http.Client
+
+	// mu only guards the cached state below, requests to the issuer are made without holding it
+	mu          sync.Mutex
+	metadata    *discovery
+	keys        map[string]any
+	keysFetched time.Time
+	// keysRefresh is closed when the running JWKS fetch, if any, finishes
+	keysRefresh chan struct{}
+}
+
+func newOIDCProvider(providerConfig config.OIDCProviderConfig, client *http.Client) *oidcProvider {
+	if len(providerConfig.Scopes) == 0 {
+		providerConfig.Scopes = defaultScopes
+	}
+
+	// Without openid the issuer answers with a plain OAuth2 token and no id_token
+	if !slices.Contains(providerConfig.Scopes, "openid") {
+		providerConfig.Scopes = append([]string{"openid"}, providerConfig.Scopes...)
+	}
+
+	return &oidcProvider{
+		config: providerConfig,
+		client: client,
+	}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse authorization endpoint: %w", err)
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientId)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+type tokenResponse struct {
+	IdToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce string) (Identity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("create token request: %w", err)
+	}
+
+	// client_secret_basic, the credentials are form encoded before they are put in the header
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token tokenResponse
+	status, err := p.do(req, &token)
+	if err != nil {
+		return Identity{}, fmt.Errorf("exchange code: %w", err)
+	}
+
+	if status != http.StatusOK || token.Error != "" {
+		return Identity{}, fmt.Errorf("exchange code: %w: %d %s %s", errors.ErrOIDCExchangeFailed, status, token.Error, token.ErrorDescription)
+	}
+
+	if token.IdToken == "" {
+		return Identity{}, fmt.Errorf("exchange code: %w: no id_token in response", errors.ErrOIDCExchangeFailed)
+	}
+
+	return p.verifyIdToken(ctx, metadata, token.IdToken, nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string    `json:"nonce"`
+	AuthorizedParty string    `json:"azp"`
+	Email           string    `json:"email"`
+	EmailVerified   boolClaim `json:"email_verified"`
+	Name            string    `json:"name"`
+}
+
+func (p *oidcProvider) verifyIdToken(ctx context.Context, metadata *discovery, idToken, nonce string) (Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims,
+		func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.key(ctx, metadata, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", errors.ErrInvalidIdToken, err)
+	}
+
+	if claims.Subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing sub", errors.ErrInvalidIdToken)
+	}
+
+	if claims.Nonce != nonce {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", errors.ErrInvalidIdToken)
+	}
+
+	// A token issued to several clients names the one it was requested by
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientId {
+		return Identity{}, fmt.Errorf("%w: azp mismatch", errors.ErrInvalidIdToken)
+	}
+
+	return Identity{
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the issuer metadata once, a failed attempt is retried on the next call
+func (p *oidcProvider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	metadata := p.metadata
+	p.mu.Unlock()
+
+	if metadata != nil {
+		return metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(p.config.IssuerURL, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create discovery request: %w", err)
+	}
+
+	var fetched discovery
+	status, err := p.do(req, &fetched)
+	if err != nil {
+		return nil, fmt.Errorf("discover %s: %w", p.config.Name, err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("discover %s: unexpected status %d", p.config.Name, status)
+	}
+
+	if strings.TrimSuffix(fetched.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discover %s: issuer %q doesn't match the configured one", p.config.Name, fetched.Issuer)
+	}
+
+	if fetched.AuthorizationEndpoint == "" || fetched.TokenEndpoint == "" || fetched.JWKSURI == "" {
+		return nil, fmt.Errorf("discover %s: incomplete metadata", p.config.Name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Concurrent first requests may all discover, the first result is kept
+	if p.metadata == nil {
+		p.metadata = &fetched
+	}
+
+	return p.metadata, nil
+}
+
+// key returns the verification key with kid, the keys are downloaded again when kid is unknown
+// so a key rotation at the issuer is picked up
+func (p *oidcProvider) key(ctx context.Context, metadata *discovery, kid string) (any, error) {
+	p.mu.Lock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		p.mu.Unlock()
+		return key, nil
+	}
+
+	// Another request is already fetching the keys, wait for it instead of fetching them again
+	if refresh := p.keysRefresh; refresh != nil {
+		p.mu.Unlock()
+
+		select {
+		case <-refresh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if key, ok := p.lookupKey(kid); ok {
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	refresh := make(chan struct{})
+	p.keysRefresh = refresh
+	p.mu.Unlock()
+
+	keys, err := p.fetchKeys(ctx, metadata.JWKSURI)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keysRefresh = nil
+	close(refresh)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// lookupKey accepts a token without kid only when the issuer has a single key
+func (p *oidcProvider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p *oidcProvider) fetchKeys(ctx context.Context, jwksURI string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create jwks request: %w", err)
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.do(req, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: unexpected status %d", status)
+	}
+
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip keys we can't use instead of failing the whole set
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode n: %w", err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode e: %w", err)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y: %w", err)
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// do sends req and decodes the JSON body into v whatever the status is, error responses have a body too
+func (p *oidcProvider) do(req *http.Request, v any) (int, error) {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("read response: %w", err)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil && res.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	return res.StatusCode, nil
+}
+
+// boolClaim accepts email_verified sent as a boolean or as a string, some issuers send "true"
+type boolClaim bool
+
+func (b *boolClaim) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean claim: %s", data)
+	}
+
+	return nil
+}
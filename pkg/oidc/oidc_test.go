@@ -0,0 +1,362 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/config"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientId     = "hotel-booking"
+	testClientSecret = "s3cr3t:with/special chars"
+	testCode         = "auth-code"
+	testNonce        = "nonce-123"
+	testKid          = "key-1"
+)
+
+// fakeIssuer is a local OpenID Connect issuer serving discovery, JWKS and a token endpoint
+// that answers testCode with idToken
+type fakeIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	jwksHits  atomic.Int32
+	idTokenMu sync.Mutex
+	idToken   string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	issuer := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, discovery{
+			Issuer:                issuer.server.URL,
+			AuthorizationEndpoint: issuer.server.URL + "/authorize",
+			TokenEndpoint:         issuer.server.URL + "/token",
+			JWKSURI:               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksHits.Add(1)
+		writeJSON(w, http.StatusOK, map[string][]jwk{
+			"keys": {{
+				Kty: "RSA",
+				Kid: testKid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, _ := r.BasicAuth()
+		clientId, _ = url.QueryUnescape(clientId)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+		if clientId != testClientId || clientSecret != testClientSecret {
+			writeJSON(w, http.StatusUnauthorized, tokenResponse{Error: "invalid_client"})
+			return
+		}
+
+		if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != testCode {
+			writeJSON(w, http.StatusBadRequest, tokenResponse{Error: "invalid_grant"})
+			return
+		}
+
+		issuer.idTokenMu.Lock()
+		defer issuer.idTokenMu.Unlock()
+		writeJSON(w, http.StatusOK, tokenResponse{IdToken: issuer.idToken})
+	})
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// claims returns the claims of a valid id token, tests change them to make it invalid
+func (f *fakeIssuer) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            f.server.URL,
+		"sub":            "user-1",
+		"aud":            testClientId,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          testNonce,
+		"email":          " Guest@Example.com ",
+		"email_verified": true,
+		"name":           "Guest",
+	}
+}
+
+// issue makes the token endpoint answer with claims signed by key
+func (f *fakeIssuer) issue(t *testing.T, claims jwt.MapClaims, key *rsa.PrivateKey) {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKid
+
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+
+	f.idTokenMu.Lock()
+	defer f.idTokenMu.Unlock()
+	f.idToken = idToken
+}
+
+func (f *fakeIssuer) provider(t *testing.T) Provider {
+	t.Helper()
+
+	registry, err := NewRegistry([]config.OIDCProviderConfig{{
+		Name:         "fake",
+		IssuerURL:    f.server.URL,
+		ClientId:     testClientId,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/fake/callback",
+	}}, f.server.Client())
+	if err != nil {
+		t.Fatalf("create registry: %v", err)
+	}
+
+	provider, ok := registry.Get("fake")
+	if !ok {
+		t.Fatal("provider fake is not registered")
+	}
+
+	return provider
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	authURL, err := issuer.provider(t).AuthCodeURL(context.Background(), "state-123", testNonce)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", authURL, err)
+	}
+
+	if got, want := parsed.Scheme+"://"+parsed.Host+parsed.Path, issuer.server.URL+"/authorize"; got != want {
+		t.Errorf("endpoint = %q, want %q", got, want)
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"response_type": "code",
+		"client_id":     testClientId,
+		"redirect_uri":  "http://localhost:8080/api/auth/oidc/fake/callback",
+		"scope":         "openid email profile",
+		"state":         "state-123",
+		"nonce":         testNonce,
+	}
+	for param, value := range want {
+		if got := query.Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		nonce    string
+		modify   func(claims jwt.MapClaims)
+		wrongKey bool
+		wantErr  error
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:    "wrong issuer",
+			modify:  func(claims jwt.MapClaims) { claims["iss"] = "https://attacker.example.com" },
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "wrong audience",
+			modify:  func(claims jwt.MapClaims) { claims["aud"] = "another-client" },
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "audience without azp",
+			modify:  func(claims jwt.MapClaims) { claims["aud"] = []string{testClientId, "another-client"} },
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "wrong nonce",
+			nonce:   "another-nonce",
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "expired",
+			modify:  func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "without expiry",
+			modify:  func(claims jwt.MapClaims) { delete(claims, "exp") },
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "without subject",
+			modify:  func(claims jwt.MapClaims) { delete(claims, "sub") },
+			wantErr: errors.ErrInvalidIdToken,
+		},
+		{
+			name:     "signed by another key",
+			wrongKey: true,
+			wantErr:  errors.ErrInvalidIdToken,
+		},
+		{
+			name:    "invalid code",
+			code:    "another-code",
+			wantErr: errors.ErrOIDCExchangeFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newFakeIssuer(t)
+
+			claims := issuer.claims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+
+			key := issuer.key
+			if tt.wrongKey {
+				key = otherKey
+			}
+			issuer.issue(t, claims, key)
+
+			code, nonce := testCode, testNonce
+			if tt.code != "" {
+				code = tt.code
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			identity, err := issuer.provider(t).Exchange(context.Background(), code, nonce)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Exchange error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+
+			want := Identity{Subject: "user-1", Email: "guest@example.com", EmailVerified: true, Name: "Guest"}
+			if identity != want {
+				t.Errorf("identity = %+v, want %+v", identity, want)
+			}
+		})
+	}
+}
+
+func TestExchangeEmailVerified(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{name: "bool", value: true, want: true},
+		{name: "string", value: "true", want: true},
+		{name: "false", value: false, want: false},
+		{name: "missing", value: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newFakeIssuer(t)
+
+			claims := issuer.claims()
+			if tt.value == nil {
+				delete(claims, "email_verified")
+			} else {
+				claims["email_verified"] = tt.value
+			}
+			issuer.issue(t, claims, issuer.key)
+
+			identity, err := issuer.provider(t).Exchange(context.Background(), testCode, testNonce)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+
+			if identity.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.want)
+			}
+		})
+	}
+}
+
+// Concurrent logins share a single JWKS download
+func TestExchangeConcurrentKeyFetch(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.issue(t, issuer.claims(), issuer.key)
+	provider := issuer.provider(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := provider.Exchange(context.Background(), testCode, testNonce)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Exchange: %v", err)
+		}
+	}
+
+	if hits := issuer.jwksHits.Load(); hits != 1 {
+		t.Errorf("jwks fetched %d times, want 1", hits)
+	}
+}
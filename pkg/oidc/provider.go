@@ -0,0 +1,61 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/config"
+)
+
+// Identity is the user an external provider authenticated
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an external identity provider. OpenID Connect issuers are configured in config.Config,
+// other kinds of providers can be added to a Registry by implementing this interface.
+type Provider interface {
+	Name() string
+	// AuthCodeURL returns the URL to send the user to, state and nonce are returned to us after the login
+	AuthCodeURL(ctx context.Context, state, nonce string) (string, error)
+	// Exchange redeems the authorization code and returns the identity, nonce must match the one sent with AuthCodeURL
+	Exchange(ctx context.Context, code, nonce string) (Identity, error)
+}
+
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry creates an OpenID Connect provider for every config, client is used for every request to the issuers
+func NewRegistry(configs []config.OIDCProviderConfig, client *http.Client) (*Registry, error) {
+	registry := Registry{
+		providers: make(map[string]Provider),
+	}
+
+	for _, providerConfig := range configs {
+		if err := registry.Register(newOIDCProvider(providerConfig, client)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &registry, nil
+}
+
+func (r *Registry) Register(provider Provider) error {
+	if _, ok := r.providers[provider.Name()]; ok {
+		return fmt.Errorf("duplicate identity provider: %s", provider.Name())
+	}
+
+	r.providers[provider.Name()] = provider
+
+	return nil
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
@@ -0,0 +1,155 @@
+package token
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultKeyId is used when the config has a single key pair instead of a keyring
+const defaultKeyId = "default"
+
+type signingKey struct {
+	id         string
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// keyring signs with the active key and verifies with any key it holds,
+// so the active key can be rotated without invalidating issued tokens
+type keyring struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func loadKeyring(tokenConfig *config.TokenConfig) (*keyring, error) {
+	keyConfigs := tokenConfig.Keys
+	activeKeyId := tokenConfig.ActiveKeyId
+
+	if len(keyConfigs) == 0 {
+		keyConfigs = []config.SigningKeyConfig{{
+			Id:             defaultKeyId,
+			PrivateKeyPath: tokenConfig.PrivateKeyPath,
+			PublicKeyPath:  tokenConfig.PublicKeyPath,
+		}}
+		activeKeyId = defaultKeyId
+	}
+
+	return newKeyring(keyConfigs, activeKeyId)
+}
+
+func newKeyring(keyConfigs []config.SigningKeyConfig, activeKeyId string) (*keyring, error) {
+	ring := keyring{
+		keys: make(map[string]*signingKey, len(keyConfigs)),
+	}
+
+	for _, keyConfig := range keyConfigs {
+		if _, ok := ring.keys[keyConfig.Id]; ok {
+			return nil, fmt.Errorf("duplicate signing key id: %s", keyConfig.Id)
+		}
+
+		key := signingKey{
+			id: keyConfig.Id,
+		}
+
+		var err error
+		key.publicKey, err = loadPublicKey(keyConfig.PublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", keyConfig.Id, err)
+		}
+
+		if keyConfig.PrivateKeyPath != "" {
+			key.privateKey, err = loadPrivateKey(keyConfig.PrivateKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %s: %w", keyConfig.Id, err)
+			}
+		}
+
+		ring.keys[key.id] = &key
+	}
+
+	active, ok := ring.keys[activeKeyId]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %s is not in the keyring", activeKeyId)
+	}
+
+	if active.privateKey == nil {
+		return nil, fmt.Errorf("active signing key %s has no private key", activeKeyId)
+	}
+
+	ring.active = active
+
+	return &ring, nil
+}
+
+// sign signs claims with the active key and sets its id as the kid header
+func (k *keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.active.id
+
+	return token.SignedString(k.active.privateKey)
+}
+
+// keyFunc picks the verification key by the kid header.
+// Tokens issued before kid headers were added are checked against every key.
+func (k *keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	// Verify that the signing algorithm is what we expect
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing kid header")
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	return key.publicKey, nil
+}
+
+func (k *keyring) jwks() JWKS {
+	jwks := JWKS{
+		Keys: make([]JWK, 0, len(k.keys)),
+	}
+
+	for _, key := range k.keys {
+		jwks.Keys = append(jwks.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Name,
+			Kid: key.id,
+			N:   base64.RawURLEncoding.EncodeToString(key.publicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.publicKey.E)).Bytes()),
+		})
+	}
+
+	// Map iteration order is random, keep the response stable for caches
+	slices.SortFunc(jwks.Keys, func(a, b JWK) int {
+		return strings.Compare(a.Kid, b.Kid)
+	})
+
+	return jwks
+}
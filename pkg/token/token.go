@@ -1,11 +1,13 @@
 package token
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"database/sql"
 	"encoding/pem"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
@@ -20,36 +22,108 @@ import (
 
 type Manager struct {
 	db                    *sql.DB
-	accessTokenPrivateKey *rsa.PrivateKey
-	accessTokenPublicKey  *rsa.PublicKey
+	keys                  *keyring
+	resetKeys             *keyring
 	accessTokenExpiresIn  time.Duration
 	refreshTokenExpiresIn time.Duration
 }
 
+// Every token kind has its own typ and aud, a token is only accepted by the parser of its kind
+const (
+	typeAccess  = "access"
+	typeRefresh = "refresh"
+	typeReset   = "reset"
+	typeMFA     = "mfa"
+	typeMagic   = "magic_link"
+	typeState   = "oidc_state"
+
+	audienceAccess  = "hotel-booking-api"
+	audienceRefresh = "hotel-booking-auth/refresh"
+	audienceReset   = "hotel-booking-auth/password-reset"
+	audienceMFA     = "hotel-booking-auth/mfa"
+	audienceMagic   = "hotel-booking-auth/magic-link"
+	audienceState   = "hotel-booking-auth/oidc-state"
+)
+
+// magicLinkExpiresIn matches how long OTPService keeps the nonce of a login link
+const (
+	resetTokenExpiresIn = 2 * time.Minute
+	mfaTokenExpiresIn   = 5 * time.Minute
+	magicLinkExpiresIn  = 10 * time.Minute
+	oidcStateExpiresIn  = 10 * time.Minute
+)
+
+type typedClaims interface {
+	jwt.Claims
+	tokenType() string
+}
+
 type CustomClaims struct {
 	jwt.RegisteredClaims
-	Role string `json:"role"`
+	Type          string              `json:"typ"`
+	Role          models.Role         `json:"role"`
+	Permissions   []models.Permission `json:"permissions,omitempty"`
+	EmailVerified bool                `json:"email_verified"`
+}
+
+// RefreshClaims only identify the session, the jti is looked up in refresh_tokens on every use
+type RefreshClaims struct {
+	jwt.RegisteredClaims
+	Type string `json:"typ"`
 }
 
 type ResetClaims struct {
 	jwt.RegisteredClaims
+	Type  string `json:"typ"`
+	Email string `json:"email"`
+}
+
+// MFAClaims prove that the password step of a login succeeded, they can't be used as an access token
+type MFAClaims struct {
+	jwt.RegisteredClaims
+	Type string `json:"typ"`
+}
+
+// MagicLinkClaims carry the nonce stored by OTPService in the jti, the link is only valid while the nonce is
+type MagicLinkClaims struct {
+	jwt.RegisteredClaims
+	Type  string `json:"typ"`
 	Email string `json:"email"`
 }
 
+// OIDCStateClaims remember which provider a login was started with and the nonce its id token must carry
+type OIDCStateClaims struct {
+	jwt.RegisteredClaims
+	Type     string `json:"typ"`
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+}
+
+func (c CustomClaims) tokenType() string    { return c.Type }
+func (c RefreshClaims) tokenType() string   { return c.Type }
+func (c ResetClaims) tokenType() string     { return c.Type }
+func (c MFAClaims) tokenType() string       { return c.Type }
+func (c MagicLinkClaims) tokenType() string { return c.Type }
+func (c OIDCStateClaims) tokenType() string { return c.Type }
+
 func NewTokenManager(db *sql.DB, tokenConfig *config.TokenConfig) (*Manager, error) {
 	tokenManager := Manager{
 		db: db,
 	}
 	var err error
 
-	tokenManager.accessTokenPrivateKey, err = loadPrivateKey(tokenConfig.PrivateKeyPath)
+	tokenManager.keys, err = loadKeyring(tokenConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token manager: %w", err)
 	}
 
-	tokenManager.accessTokenPublicKey, err = loadPublicKey(tokenConfig.PublicKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create token manager: %w", err)
+	// Reset tokens share the keyring unless they have their own key
+	tokenManager.resetKeys = tokenManager.keys
+	if tokenConfig.ResetKey != nil {
+		tokenManager.resetKeys, err = newKeyring([]config.SigningKeyConfig{*tokenConfig.ResetKey}, tokenConfig.ResetKey.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create token manager: %w", err)
+		}
 	}
 
 	tokenManager.accessTokenExpiresIn = time.Duration(tokenConfig.AccessTokenExpiresIn) * time.Minute
@@ -58,7 +132,13 @@ func NewTokenManager(db *sql.DB, tokenConfig *config.TokenConfig) (*Manager, err
 	return &tokenManager, nil
 }
 
-func (m *Manager) GenerateAccessToken(userId, role string) (string, error) {
+// GenerateAccessToken embeds the permissions granted to the user's role and whether their email is verified in the token
+func (m *Manager) GenerateAccessToken(user *models.User) (string, error) {
+	permissions, err := m.rolePermissions(user.Role)
+	if err != nil {
+		return "", fmt.Errorf("error getting role permissions: %w", err)
+	}
+
 	now := time.Now()
 
 	claims := CustomClaims{
@@ -66,14 +146,16 @@ func (m *Manager) GenerateAccessToken(userId, role string) (string, error) {
 			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTokenExpiresIn)),
 			NotBefore: jwt.NewNumericDate(now),
 			IssuedAt:  jwt.NewNumericDate(now),
-			Subject:   userId,
+			Subject:   user.Id.String(),
+			Audience:  jwt.ClaimStrings{audienceAccess},
 		},
-		Role: role,
+		Type:          typeAccess,
+		Role:          user.Role,
+		Permissions:   permissions,
+		EmailVerified: user.IsEmailVerified(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-
-	signedToken, err := token.SignedString(m.accessTokenPrivateKey)
+	signedToken, err := m.keys.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("error signing access token: %w", err)
 	}
@@ -81,176 +163,384 @@ func (m *Manager) GenerateAccessToken(userId, role string) (string, error) {
 	return signedToken, nil
 }
 
+// GenerateResetToken issues a password reset token, its jti is stored so the token can be used only once
 func (m *Manager) GenerateResetToken(email string) (string, error) {
+	jti := uuid.NewString()
 	now := time.Now()
+	expiry := now.Add(resetTokenExpiresIn)
 
 	claims := ResetClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Minute)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiry),
 			NotBefore: jwt.NewNumericDate(now),
 			IssuedAt:  jwt.NewNumericDate(now),
+			Audience:  jwt.ClaimStrings{audienceReset},
 		},
+		Type:  typeReset,
 		Email: email,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	signedToken, err := m.resetKeys.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error signing reset token: %w", err)
+	}
 
-	signedToken, err := token.SignedString(m.accessTokenPrivateKey)
+	_, err = m.db.Exec(queries.DeleteExpiredResetTokens,
+		sql.Named("email", email),
+		sql.Named("now", now),
+	)
 	if err != nil {
-		return "", fmt.Errorf("error signing access token: %w", err)
+		return "", fmt.Errorf("error deleting expired reset tokens: %w", err)
+	}
+
+	_, err = m.db.Exec(queries.InsertResetToken,
+		sql.Named("jti_hash", utils.Hash(jti)),
+		sql.Named("email", email),
+		sql.Named("expires_at", expiry),
+		sql.Named("created_at", now),
+	)
+	if err != nil {
+		return "", fmt.Errorf("db save error: %w", err)
 	}
 
 	return signedToken, nil
 }
 
-func (m *Manager) ParseAccessToken(accessToken string) (*CustomClaims, error) {
-	token, err := jwt.ParseWithClaims(
-		accessToken,
-		&CustomClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			// Verify that the signing algorithm is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// Return the public key for verification
-			return m.accessTokenPublicKey, nil
+// GenerateMFAToken issues a short lived token that is exchanged for a session once the second factor is verified
+func (m *Manager) GenerateMFAToken(uid uuid.UUID) (string, error) {
+	now := time.Now()
+
+	claims := MFAClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenExpiresIn)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   uid.String(),
+			Audience:  jwt.ClaimStrings{audienceMFA},
 		},
-		// Additional validation options
-		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
-		jwt.WithExpirationRequired(),
-		jwt.WithIssuedAt(),
+		Type: typeMFA,
+	}
+
+	signedToken, err := m.keys.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error signing mfa token: %w", err)
+	}
+
+	return signedToken, nil
+}
+
+// ParseMFAToken returns the id of the user who passed the password step
+func (m *Manager) ParseMFAToken(mfaToken string) (uuid.UUID, error) {
+	var claims MFAClaims
+	if err := parseToken(m.keys, mfaToken, &claims, typeMFA, audienceMFA); err != nil {
+		return uuid.Nil, err
+	}
+
+	uid, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid mfa token subject: %w", errors.ErrInvalidToken)
+	}
+
+	return uid, nil
+}
+
+// GenerateMagicLinkToken signs the login link token for email, nonce must already be stored by OTPService
+func (m *Manager) GenerateMagicLinkToken(email, nonce string) (string, error) {
+	now := time.Now()
+
+	claims := MagicLinkClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        nonce,
+			ExpiresAt: jwt.NewNumericDate(now.Add(magicLinkExpiresIn)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Audience:  jwt.ClaimStrings{audienceMagic},
+		},
+		Type:  typeMagic,
+		Email: email,
+	}
+
+	signedToken, err := m.keys.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error signing magic link token: %w", err)
+	}
+
+	return signedToken, nil
+}
+
+// ParseMagicLinkToken returns the email and the nonce of a login link, the nonce still has to be consumed
+func (m *Manager) ParseMagicLinkToken(magicLinkToken string) (string, string, error) {
+	var claims MagicLinkClaims
+	if err := parseToken(m.keys, magicLinkToken, &claims, typeMagic, audienceMagic); err != nil {
+		return "", "", err
+	}
+
+	if claims.Email == "" || claims.ID == "" {
+		return "", "", fmt.Errorf("missing magic link token claims: %w", errors.ErrInvalidToken)
+	}
+
+	return claims.Email, claims.ID, nil
+}
+
+// GenerateOIDCState signs the state sent to an identity provider, so the callback doesn't need server side storage
+func (m *Manager) GenerateOIDCState(provider, nonce string) (string, error) {
+	now := time.Now()
+
+	claims := OIDCStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateExpiresIn)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Audience:  jwt.ClaimStrings{audienceState},
+		},
+		Type:     typeState,
+		Provider: provider,
+		Nonce:    nonce,
+	}
+
+	signedToken, err := m.keys.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error signing oidc state: %w", err)
+	}
+
+	return signedToken, nil
+}
+
+// ParseOIDCState returns the provider and the nonce of a state created by GenerateOIDCState
+func (m *Manager) ParseOIDCState(state string) (string, string, error) {
+	var claims OIDCStateClaims
+	if err := parseToken(m.keys, state, &claims, typeState, audienceState); err != nil {
+		return "", "", err
+	}
+
+	if claims.Provider == "" || claims.Nonce == "" {
+		return "", "", fmt.Errorf("missing oidc state claims: %w", errors.ErrInvalidToken)
+	}
+
+	return claims.Provider, claims.Nonce, nil
+}
+
+func (m *Manager) ParseAccessToken(accessToken string) (*CustomClaims, error) {
+	var claims CustomClaims
+	if err := parseToken(m.keys, accessToken, &claims, typeAccess, audienceAccess); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+// ConsumeResetToken verifies the reset token and marks it as used, it returns the email the token was issued for
+func (m *Manager) ConsumeResetToken(resetToken string) (string, error) {
+	var claims ResetClaims
+	if err := parseToken(m.resetKeys, resetToken, &claims, typeReset, audienceReset); err != nil {
+		return "", err
+	}
+
+	if claims.Email == "" || claims.ID == "" {
+		return "", fmt.Errorf("missing reset token claims: %w", errors.ErrInvalidToken)
+	}
+
+	res, err := m.db.Exec(queries.ConsumeResetToken,
+		sql.Named("now", time.Now()),
+		sql.Named("jti_hash", utils.Hash(claims.ID)),
+		sql.Named("email", claims.Email),
 	)
 	if err != nil {
-		switch {
-		case errors.Is(err, jwt.ErrTokenExpired):
-			return nil, fmt.Errorf("token expired: %w", err)
-		case errors.Is(err, jwt.ErrTokenNotValidYet):
-			return nil, fmt.Errorf("token not valid yet: %w", err)
-		default:
-			return nil, fmt.Errorf("invalid token: %w", err)
-		}
+		return "", fmt.Errorf("error consuming reset token: %w", err)
 	}
 
-	// Verify token is valid and extract claims
-	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("error getting rows affected: %w", err)
 	}
 
-	// Type assert the claims
-	claims, ok := token.Claims.(*CustomClaims)
-	if !ok {
-		return nil, fmt.Errorf("invalid token claims")
+	if rowsAffected == 0 {
+		return "", errors.ErrResetTokenUsed
 	}
 
-	return claims, nil
+	return claims.Email, nil
 }
 
-func (m *Manager) ParseResetToken(resetToken string) (string, error) {
+func (m *Manager) parseRefreshToken(refreshToken string) (*RefreshClaims, error) {
+	var claims RefreshClaims
+	if err := parseToken(m.keys, refreshToken, &claims, typeRefresh, audienceRefresh); err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" || claims.Subject == "" {
+		return nil, fmt.Errorf("missing refresh token claims: %w", errors.ErrInvalidToken)
+	}
+
+	return &claims, nil
+}
+
+// parseToken verifies tokenString against keys and decodes it into claims.
+// Besides the signature and the time based claims, typ and aud must match the expected token kind.
+func parseToken(keys *keyring, tokenString string, claims typedClaims, tokenType, audience string) error {
 	token, err := jwt.ParseWithClaims(
-		resetToken,
-		&ResetClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			// Verify that the signing algorithm is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// Return the public key for verification
-			return m.accessTokenPublicKey, nil
-		},
+		tokenString,
+		claims,
+		keys.keyFunc,
 		// Additional validation options
 		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
 		jwt.WithExpirationRequired(),
 		jwt.WithIssuedAt(),
+		jwt.WithAudience(audience),
 	)
 	if err != nil {
 		switch {
 		case errors.Is(err, jwt.ErrTokenExpired):
-			return "", fmt.Errorf("token expired: %w", err)
+			return fmt.Errorf("token expired: %w", errors.ErrTokenExpired)
 		case errors.Is(err, jwt.ErrTokenNotValidYet):
-			return "", fmt.Errorf("token not valid yet: %w", err)
+			return fmt.Errorf("token not valid yet: %w", err)
 		default:
-			return "", fmt.Errorf("invalid token: %w", err)
+			return fmt.Errorf("invalid token: %w", err)
 		}
 	}
 
-	// Verify token is valid and extract claims
+	// Verify token is valid
 	if !token.Valid {
-		return "", fmt.Errorf("invalid token")
+		return fmt.Errorf("invalid token")
 	}
 
-	// Type assert the claims
-	claims, ok := token.Claims.(*ResetClaims)
-	if !ok {
-		return "", fmt.Errorf("invalid token claims")
+	if claims.tokenType() != tokenType {
+		return fmt.Errorf("invalid token type: %w", errors.ErrInvalidToken)
 	}
 
-	return claims.Email, nil
+	return nil
+}
+
+// GenerateRefreshToken starts a new session for the device, sessions on other devices are kept
+func (m *Manager) GenerateRefreshToken(uid uuid.UUID, device models.DeviceInfo) (string, error) {
+	_, err := m.db.Exec(queries.DeleteExpiredRefreshTokens,
+		sql.Named("user_id", uid),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error deleting expired refresh tokens: %w", err)
+	}
+
+	return m.insertRefreshToken(m.db, uid, uuid.New(), device)
 }
 
-// TODO: Refactor => JWT refresh token, store hash of the token on Db
-func (m *Manager) GenerateRefreshToken(uid uuid.UUID) (string, error) {
-	expired, err := m.isRefreshTokenExpired(uid)
+// RotateRefreshToken invalidates the presented token and issues the next token of its family
+func (m *Manager) RotateRefreshToken(session models.RefreshToken, device models.DeviceInfo) (string, error) {
+	tx, err := m.db.BeginTx(context.Background(), nil)
 	if err != nil {
-		return "", fmt.Errorf("error checking refresh token expiry: %w", err)
+		return "", fmt.Errorf("begin rotate refresh token tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	if expired {
-		return "", errors.New("expired token")
+	res, err := tx.Exec(queries.RotateRefreshToken,
+		sql.Named("rotated_at", time.Now()),
+		sql.Named("id", session.Id),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error rotating refresh token: %w", err)
 	}
 
-	token, err := utils.RandString(32)
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return "", fmt.Errorf("error generating refresh token: %w", err)
+		return "", fmt.Errorf("error getting rows affected: %w", err)
 	}
 
-	id := uuid.New()
-	now := time.Now()
-	expiry := now.Add(m.refreshTokenExpiresIn)
+	// Another request rotated the same token first
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return "", m.revokeFamily(session)
+	}
 
-	var execErr error
-	if errors.Is(err, sql.ErrNoRows) {
-		_, execErr = m.db.Exec(queries.InsertRefreshToken,
-			sql.Named("id", id),
-			sql.Named("user_id", uid),
-			sql.Named("token_hash", utils.Hash(token)),
-			sql.Named("created_at", now),
-			sql.Named("expires_at", expiry),
-		)
-	} else {
-		_, execErr = m.db.Exec(queries.UpdateRefreshToken,
-			sql.Named("token_hash", utils.Hash(token)),
-			sql.Named("created_at", now),
-			sql.Named("expires_at", expiry),
-			sql.Named("user_id", uid))
+	token, err := m.insertRefreshToken(tx, session.UserId, session.FamilyId, device)
+	if err != nil {
+		return "", err
 	}
 
-	if execErr != nil {
-		return "", fmt.Errorf("db save error: %w", execErr)
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit rotate refresh token tx: %w", err)
 	}
 
 	return token, nil
 }
 
-func (m *Manager) ValidateRefreshToken(refreshToken string) (uuid.UUID, error) {
-	var token models.RefreshToken
+// ValidateRefreshToken verifies the refresh JWT and returns the session its jti belongs to.
+// Presenting a token that was already rotated revokes its whole family.
+func (m *Manager) ValidateRefreshToken(refreshToken string) (models.RefreshToken, error) {
+	claims, err := m.parseRefreshToken(refreshToken)
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
 
-	err := m.db.QueryRow(queries.SelectRefreshToken, sql.Named("token_hash", utils.Hash(refreshToken))).Scan(&token.Id, &token.UserId, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt)
+	token, err := scanRefreshToken(m.db.QueryRow(queries.SelectRefreshToken, sql.Named("token_hash", utils.Hash(claims.ID))))
 	if err != nil {
-		// Todo: if sql.ErrNoRows return custom error
-		return uuid.Nil, fmt.Errorf("check refresh token is expired: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, errors.ErrNotFoundRefreshToken
+		}
+		return models.RefreshToken{}, fmt.Errorf("get refresh token: %w", err)
+	}
+
+	if token.UserId.String() != claims.Subject {
+		return models.RefreshToken{}, fmt.Errorf("refresh token subject mismatch: %w", errors.ErrInvalidToken)
 	}
 
+	if token.IsRotated() {
+		return models.RefreshToken{}, m.revokeFamily(token)
+	}
+
+	// The row can be expired earlier than the JWT when the session is shortened server side
 	if time.Now().After(token.ExpiresAt) {
-		return uuid.Nil, errors.ErrTokenExpired
+		return models.RefreshToken{}, errors.ErrTokenExpired
+	}
+
+	return token, nil
+}
+
+// JWKS returns the public keys of the keyring for other services to verify tokens
+func (m *Manager) JWKS() JWKS {
+	return m.keys.jwks()
+}
+
+// RevokeRefreshTokens ends every session of the user
+func (m *Manager) RevokeRefreshTokens(uid uuid.UUID) error {
+	_, err := m.db.Exec(queries.DeleteRefreshTokensByUserId, sql.Named("user_id", uid))
+	if err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %w", err)
 	}
 
-	return token.UserId, nil
+	return nil
+}
+
+// Sessions returns the active sessions of the user, newest first
+func (m *Manager) Sessions(uid uuid.UUID) ([]models.RefreshToken, error) {
+	rows, err := m.db.Query(queries.SelectRefreshTokensByUserId,
+		sql.Named("user_id", uid),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sessions: %w", err)
+	}
+
+	defer rows.Close()
+
+	sessions := []models.RefreshToken{}
+	for rows.Next() {
+		session, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning session: %w", err)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
 }
 
-func (m *Manager) DeleteRefreshToken(uid uuid.UUID) error {
-	res, err := m.db.Exec(queries.DeleteRefreshToken, sql.Named("user_id", uid))
+// DeleteRefreshToken ends a single session of the user
+func (m *Manager) DeleteRefreshToken(uid, sessionId uuid.UUID) error {
+	res, err := m.db.Exec(queries.DeleteRefreshToken,
+		sql.Named("id", sessionId),
+		sql.Named("user_id", uid),
+	)
 	if err != nil {
 		return fmt.Errorf("error deleting refresh token: %w", err)
 	}
@@ -267,18 +557,110 @@ func (m *Manager) DeleteRefreshToken(uid uuid.UUID) error {
 	return nil
 }
 
-func (m *Manager) isRefreshTokenExpired(uid uuid.UUID) (bool, error) {
-	var expiry time.Time
-
-	err := m.db.QueryRow(queries.ExpiryCheck, sql.Named("user_id", uid)).Scan(&expiry)
+func (m *Manager) rolePermissions(role models.Role) ([]models.Permission, error) {
+	rows, err := m.db.Query(queries.SelectPermissionsByRole, sql.Named("role", role))
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
 		}
-		return false, fmt.Errorf("check refresh token is expired: %w", err)
+
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertRefreshToken signs a refresh JWT and stores the hash of its jti, the token itself is never stored
+func (m *Manager) insertRefreshToken(db execer, uid, familyId uuid.UUID, device models.DeviceInfo) (string, error) {
+	jti := uuid.NewString()
+	now := time.Now()
+	expiry := now.Add(m.refreshTokenExpiresIn)
+
+	claims := RefreshClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   uid.String(),
+			ExpiresAt: jwt.NewNumericDate(expiry),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Audience:  jwt.ClaimStrings{audienceRefresh},
+		},
+		Type: typeRefresh,
+	}
+
+	token, err := m.keys.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error signing refresh token: %w", err)
 	}
 
-	return time.Now().After(expiry), nil
+	_, err = db.Exec(queries.InsertRefreshToken,
+		sql.Named("id", uuid.New()),
+		sql.Named("user_id", uid),
+		sql.Named("token_hash", utils.Hash(jti)),
+		sql.Named("created_at", now),
+		sql.Named("expires_at", expiry),
+		sql.Named("ip", device.IP),
+		sql.Named("user_agent", device.UserAgent),
+		sql.Named("os", device.OS),
+		sql.Named("browser", device.Browser),
+		sql.Named("device", device.Device),
+		sql.Named("family_id", familyId),
+	)
+	if err != nil {
+		return "", fmt.Errorf("db save error: %w", err)
+	}
+
+	return token, nil
+}
+
+// revokeFamily is called when a rotated token is presented again, either the token was stolen
+// or the client replayed it. Both the attacker and the user have to log in again.
+func (m *Manager) revokeFamily(token models.RefreshToken) error {
+	log.Printf("security: refresh token reuse detected, user=%s family=%s token=%s ip=%s", token.UserId, token.FamilyId, token.Id, token.DeviceInfo.IP)
+
+	_, err := m.db.Exec(queries.DeleteRefreshTokenFamily, sql.Named("family_id", token.FamilyId))
+	if err != nil {
+		return fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+
+	return errors.ErrRefreshTokenReused
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRefreshToken(row rowScanner) (models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := row.Scan(
+		&token.Id,
+		&token.UserId,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&token.DeviceInfo.IP,
+		&token.DeviceInfo.UserAgent,
+		&token.DeviceInfo.OS,
+		&token.DeviceInfo.Browser,
+		&token.DeviceInfo.Device,
+		&token.FamilyId,
+		&token.RotatedAt,
+	)
+
+	return token, err
 }
 
 func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
@@ -0,0 +1,485 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AkifhanIlgaz/hotel-booking-app/config"
+	"github.com/AkifhanIlgaz/hotel-booking-app/internal/models"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations"
+	"github.com/AkifhanIlgaz/hotel-booking-app/migrations/queries"
+	"github.com/AkifhanIlgaz/hotel-booking-app/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+// writeTestKey generates an RSA key pair and writes it in the PEM files the config points to
+func writeTestKey(t *testing.T, id string) config.SigningKeyConfig {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	keyConfig := config.SigningKeyConfig{
+		Id:             id,
+		PrivateKeyPath: filepath.Join(dir, id+".pem"),
+		PublicKeyPath:  filepath.Join(dir, id+".pub"),
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyConfig.PrivateKeyPath, privatePEM, 0o600); err != nil {
+		t.Fatalf("write private key: %v", err)
+	}
+
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	if err := os.WriteFile(keyConfig.PublicKeyPath, publicPEM, 0o644); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+
+	return keyConfig
+}
+
+func newTestKeyring(t *testing.T, id string) *keyring {
+	t.Helper()
+
+	keys, err := newKeyring([]config.SigningKeyConfig{writeTestKey(t, id)}, id)
+	if err != nil {
+		t.Fatalf("create keyring: %v", err)
+	}
+
+	return keys
+}
+
+// tokenKind is a kind of token and the parser the Manager uses for it
+type tokenKind struct {
+	name     string
+	typ      string
+	audience string
+	parse    func(keys *keyring, tokenString string) error
+}
+
+var (
+	accessKind = tokenKind{"access", typeAccess, audienceAccess, func(keys *keyring, tokenString string) error {
+		return parseToken(keys, tokenString, &CustomClaims{}, typeAccess, audienceAccess)
+	}}
+	refreshKind = tokenKind{"refresh", typeRefresh, audienceRefresh, func(keys *keyring, tokenString string) error {
+		return parseToken(keys, tokenString, &RefreshClaims{}, typeRefresh, audienceRefresh)
+	}}
+	resetKind = tokenKind{"reset", typeReset, audienceReset, func(keys *keyring, tokenString string) error {
+		return parseToken(keys, tokenString, &ResetClaims{}, typeReset, audienceReset)
+	}}
+	mfaKind = tokenKind{"mfa", typeMFA, audienceMFA, func(keys *keyring, tokenString string) error {
+		return parseToken(keys, tokenString, &MFAClaims{}, typeMFA, audienceMFA)
+	}}
+	magicKind = tokenKind{"magic link", typeMagic, audienceMagic, func(keys *keyring, tokenString string) error {
+		return parseToken(keys, tokenString, &MagicLinkClaims{}, typeMagic, audienceMagic)
+	}}
+	stateKind = tokenKind{"oidc state", typeState, audienceState, func(keys *keyring, tokenString string) error {
+		return parseToken(keys, tokenString, &OIDCStateClaims{}, typeState, audienceState)
+	}}
+
+	tokenKinds = []tokenKind{accessKind, refreshKind, resetKind, mfaKind, magicKind, stateKind}
+)
+
+func testClaims(typ, audience string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub": "00000000-0000-0000-0000-000000000001",
+		"jti": "00000000-0000-0000-0000-000000000002",
+		"aud": []string{audience},
+		"typ": typ,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}
+}
+
+func signTestToken(t *testing.T, keys *keyring, claims jwt.Claims) string {
+	t.Helper()
+
+	tokenString, err := keys.sign(claims)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	return tokenString
+}
+
+// Every token is accepted by the parser of its kind and rejected by all the others
+func TestParseTokenKinds(t *testing.T) {
+	keys := newTestKeyring(t, "test")
+
+	for _, issued := range tokenKinds {
+		tokenString := signTestToken(t, keys, testClaims(issued.typ, issued.audience))
+
+		for _, parser := range tokenKinds {
+			t.Run(issued.name+" token as "+parser.name, func(t *testing.T) {
+				err := parser.parse(keys, tokenString)
+				if parser.name == issued.name && err != nil {
+					t.Fatalf("parse: %v", err)
+				}
+
+				if parser.name != issued.name && err == nil {
+					t.Fatal("parse accepted a token of another kind")
+				}
+			})
+		}
+	}
+}
+
+func TestParseTokenRejects(t *testing.T) {
+	keys := newTestKeyring(t, "test")
+	otherKeys := newTestKeyring(t, "other")
+	// Same kid as keys but another private key, like a key from another environment
+	impostorKeys := newTestKeyring(t, "test")
+
+	tests := []struct {
+		name   string
+		parser tokenKind
+		token  func(t *testing.T) string
+	}{
+		{
+			name:   "reset token with the access audience",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				return signTestToken(t, keys, testClaims(typeReset, audienceAccess))
+			},
+		},
+		{
+			name:   "access token with the reset audience",
+			parser: resetKind,
+			token: func(t *testing.T) string {
+				return signTestToken(t, keys, testClaims(typeAccess, audienceReset))
+			},
+		},
+		{
+			name:   "wrong audience",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				return signTestToken(t, keys, testClaims(typeAccess, "another-api"))
+			},
+		},
+		{
+			name:   "without audience",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				claims := testClaims(typeAccess, audienceAccess)
+				delete(claims, "aud")
+				return signTestToken(t, keys, claims)
+			},
+		},
+		{
+			name:   "wrong type",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				return signTestToken(t, keys, testClaims("id", audienceAccess))
+			},
+		},
+		{
+			name:   "without type",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				claims := testClaims(typeAccess, audienceAccess)
+				delete(claims, "typ")
+				return signTestToken(t, keys, claims)
+			},
+		},
+		{
+			name:   "unknown key id",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				return signTestToken(t, otherKeys, testClaims(typeAccess, audienceAccess))
+			},
+		},
+		{
+			name:   "signed with the wrong key",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				return signTestToken(t, impostorKeys, testClaims(typeAccess, audienceAccess))
+			},
+		},
+		{
+			name:   "signed with the wrong key without kid",
+			parser: resetKind,
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(typeReset, audienceReset))
+				tokenString, err := token.SignedString(otherKeys.active.privateKey)
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return tokenString
+			},
+		},
+		{
+			name:   "signed with the right key without kid",
+			parser: resetKind,
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(typeReset, audienceReset))
+				tokenString, err := token.SignedString(keys.active.privateKey)
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return tokenString
+			},
+		},
+		{
+			name:   "HMAC signed with the public key",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(typeAccess, audienceAccess))
+				token.Header["kid"] = "test"
+				tokenString, err := token.SignedString(x509.MarshalPKCS1PublicKey(keys.active.publicKey))
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return tokenString
+			},
+		},
+		{
+			name:   "unsigned",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims(typeAccess, audienceAccess))
+				tokenString, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return tokenString
+			},
+		},
+		{
+			name:   "expired",
+			parser: resetKind,
+			token: func(t *testing.T) string {
+				claims := testClaims(typeReset, audienceReset)
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return signTestToken(t, keys, claims)
+			},
+		},
+		{
+			name:   "without expiry",
+			parser: resetKind,
+			token: func(t *testing.T) string {
+				claims := testClaims(typeReset, audienceReset)
+				delete(claims, "exp")
+				return signTestToken(t, keys, claims)
+			},
+		},
+		{
+			name:   "not valid yet",
+			parser: accessKind,
+			token: func(t *testing.T) string {
+				claims := testClaims(typeAccess, audienceAccess)
+				claims["nbf"] = time.Now().Add(time.Hour).Unix()
+				return signTestToken(t, keys, claims)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.parser.parse(keys, tt.token(t)); err == nil {
+				t.Fatal("parse accepted the token")
+			}
+		})
+	}
+}
+
+// A reset token signed with the dedicated reset key is not accepted by the access keyring and the other way around
+func TestParseTokenResetKey(t *testing.T) {
+	keys := newTestKeyring(t, "test")
+	resetKeys := newTestKeyring(t, "reset")
+
+	resetToken := signTestToken(t, resetKeys, testClaims(typeReset, audienceReset))
+	if err := parseToken(keys, resetToken, &ResetClaims{}, typeReset, audienceReset); err == nil {
+		t.Error("the access keyring accepted a token signed with the reset key")
+	}
+
+	if err := parseToken(resetKeys, resetToken, &ResetClaims{}, typeReset, audienceReset); err != nil {
+		t.Errorf("parse reset token: %v", err)
+	}
+
+	resetTokenFromKeyring := signTestToken(t, keys, testClaims(typeReset, audienceReset))
+	if err := parseToken(resetKeys, resetTokenFromKeyring, &ResetClaims{}, typeReset, audienceReset); err == nil {
+		t.Error("the reset keyring accepted a token signed with the access keyring")
+	}
+}
+
+// newTestManager creates a Manager on the SQL Server named by TEST_DATABASE_DSN and a user to issue tokens for,
+// tests that need a database are skipped when it isn't set
+func newTestManager(t *testing.T) (*Manager, uuid.UUID) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := sql.Open("sqlserver", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrations.Migrate(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	uid := uuid.New()
+	_, err = db.Exec(queries.InsertUser,
+		sql.Named("id", uid),
+		sql.Named("name", "Guest"),
+		sql.Named("email", uid.String()+"@example.com"),
+		sql.Named("password_hash", "not a hash"),
+		sql.Named("role", models.RoleUser),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	keyConfig := writeTestKey(t, "test")
+	manager, err := NewTokenManager(db, &config.TokenConfig{
+		PrivateKeyPath:        keyConfig.PrivateKeyPath,
+		PublicKeyPath:         keyConfig.PublicKeyPath,
+		AccessTokenExpiresIn:  15,
+		RefreshTokenExpiresIn: 7,
+	})
+	if err != nil {
+		t.Fatalf("create token manager: %v", err)
+	}
+
+	return manager, uid
+}
+
+// Presenting a token again after it was rotated ends the whole session
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	manager, uid := newTestManager(t)
+	device := models.DeviceInfo{IP: "127.0.0.1", Browser: "Firefox"}
+
+	first, err := manager.GenerateRefreshToken(uid, device)
+	if err != nil {
+		t.Fatalf("generate refresh token: %v", err)
+	}
+
+	session, err := manager.ValidateRefreshToken(first)
+	if err != nil {
+		t.Fatalf("validate first token: %v", err)
+	}
+
+	second, err := manager.RotateRefreshToken(session, device)
+	if err != nil {
+		t.Fatalf("rotate first token: %v", err)
+	}
+
+	if _, err := manager.ValidateRefreshToken(first); !errors.Is(err, errors.ErrRefreshTokenReused) {
+		t.Fatalf("reusing the rotated token: error = %v, want %v", err, errors.ErrRefreshTokenReused)
+	}
+
+	if _, err := manager.ValidateRefreshToken(second); !errors.Is(err, errors.ErrNotFoundRefreshToken) {
+		t.Fatalf("token issued by the rotation after reuse: error = %v, want %v", err, errors.ErrNotFoundRefreshToken)
+	}
+}
+
+// Sessions on other devices are not revoked with the reused family
+func TestRotateRefreshTokenReuseKeepsOtherSessions(t *testing.T) {
+	manager, uid := newTestManager(t)
+
+	first, err := manager.GenerateRefreshToken(uid, models.DeviceInfo{Device: "phone"})
+	if err != nil {
+		t.Fatalf("generate refresh token: %v", err)
+	}
+
+	other, err := manager.GenerateRefreshToken(uid, models.DeviceInfo{Device: "laptop"})
+	if err != nil {
+		t.Fatalf("generate refresh token: %v", err)
+	}
+
+	session, err := manager.ValidateRefreshToken(first)
+	if err != nil {
+		t.Fatalf("validate first token: %v", err)
+	}
+
+	if _, err := manager.RotateRefreshToken(session, session.DeviceInfo); err != nil {
+		t.Fatalf("rotate first token: %v", err)
+	}
+
+	if _, err := manager.ValidateRefreshToken(first); !errors.Is(err, errors.ErrRefreshTokenReused) {
+		t.Fatalf("reusing the rotated token: error = %v, want %v", err, errors.ErrRefreshTokenReused)
+	}
+
+	if _, err := manager.ValidateRefreshToken(other); err != nil {
+		t.Fatalf("session on another device: %v", err)
+	}
+}
+
+// Two refreshes racing with the same token both pass validation, only one of them may rotate it
+func TestRotateRefreshTokenConcurrent(t *testing.T) {
+	manager, uid := newTestManager(t)
+	device := models.DeviceInfo{IP: "127.0.0.1"}
+
+	first, err := manager.GenerateRefreshToken(uid, device)
+	if err != nil {
+		t.Fatalf("generate refresh token: %v", err)
+	}
+
+	session, err := manager.ValidateRefreshToken(first)
+	if err != nil {
+		t.Fatalf("validate first token: %v", err)
+	}
+
+	const refreshes = 2
+	tokens := make([]string, refreshes)
+	errs := make([]error, refreshes)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range refreshes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			tokens[i], errs[i] = manager.RotateRefreshToken(session, device)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	var rotated string
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+			rotated = tokens[i]
+		case !errors.Is(err, errors.ErrRefreshTokenReused):
+			t.Errorf("refresh %d: error = %v, want %v", i, err, errors.ErrRefreshTokenReused)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Fatalf("%d refreshes succeeded, want 1", succeeded)
+	}
+
+	// The losing refresh treats the race as reuse, so the family including the winner's token is gone
+	if _, err := manager.ValidateRefreshToken(rotated); !errors.Is(err, errors.ErrNotFoundRefreshToken) {
+		t.Fatalf("token issued by the winning refresh: error = %v, want %v", err, errors.ErrNotFoundRefreshToken)
+	}
+}
@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 // RandString generates a random string of a specified length.
@@ -33,3 +36,71 @@ func Hash(token string) string {
 	hashedBytes := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hashedBytes[:])
 }
+
+// encryptedPrefix versions the values sealed by Cipher
+const encryptedPrefix = "v1:"
+
+// Cipher encrypts secrets the server has to read back, like TOTP secrets, before they are stored.
+// It uses AES-256-GCM, the associated data binds a value to its row so it can't be copied to another one.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a Cipher from a base64 encoded 32 byte key
+func NewCipher(encodedKey string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode encryption key: %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Encrypt(plaintext, associatedData string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext, associatedData string) (string, error) {
+	encoded, ok := strings.CutPrefix(ciphertext, encryptedPrefix)
+	if !ok {
+		return "", fmt.Errorf("decrypt: value is not encrypted")
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+
+	if len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("decrypt: value is too short")
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, []byte(associatedData))
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import "strings"
+
+// ParseUserAgent makes a best effort guess of the operating system, browser and device type of a User-Agent header.
+// Unknown values are returned as "Other".
+func ParseUserAgent(userAgent string) (os, browser, device string) {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os"), strings.Contains(ua, "macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	default:
+		os = "Other"
+	}
+
+	// Order matters, most browsers include the tokens of the engines they are built on
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/"), strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"), strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	default:
+		browser = "Other"
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"):
+		device = "Tablet"
+	case strings.Contains(ua, "mobile"), strings.Contains(ua, "iphone"), strings.Contains(ua, "android"):
+		device = "Mobile"
+	case ua == "":
+		device = "Other"
+	default:
+		device = "Desktop"
+	}
+
+	return os, browser, device
+}